@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/frozendolphin/Chirpy/internal/auth"
+	"github.com/frozendolphin/Chirpy/internal/database"
+	"github.com/frozendolphin/Chirpy/internal/problem"
+)
+
+const authRealm = "chirpy"
+
+type authFailureKey struct{}
+
+type authUserKey struct{}
+
+// authenticate works out who the request is from, once, before anything
+// that cares: the rate limiter, the route and the request log. It never
+// rejects a request itself. Routes say whether they need a principal with
+// requireAuth, optionalAuth or requireRefreshToken.
+func (cfg *apiConfig) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		token, err := auth.GetBearerToken(r.Header)
+		if errors.Is(err, auth.ErrNoToken) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := r.Context()
+		principal, user, err := cfg.principalFor(ctx, token, err)
+		if err != nil {
+			// the token might be a refresh token meant for /api/refresh, so
+			// leave it to the route to decide whether this is fatal
+			next.ServeHTTP(w, withAuthFailure(r, err))
+			return
+		}
+
+		if rec := recorderOf(w); rec != nil {
+			rec.userID = principal.UserID.String()
+		}
+		ctx = context.WithValue(ctx, authUserKey{}, user)
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(ctx, principal)))
+	})
+}
+
+// principalFor validates an access token and fills in the roles of the user
+// it belongs to. Roles come from the database so that a demotion applies at
+// once rather than when the token expires. The user row is returned too, so
+// role and standing checks later in the request needn't load it again.
+func (cfg *apiConfig) principalFor(ctx context.Context, token string, err error) (auth.Principal, database.User, error) {
+
+	if err != nil {
+		return auth.Principal{}, database.User{}, err
+	}
+
+	principal, err := auth.ParseAccessToken(token, cfg.secret)
+	if err != nil {
+		return auth.Principal{}, database.User{}, err
+	}
+
+	user, err := cfg.db.GetUserByID(ctx, principal.UserID)
+	if err != nil {
+		return auth.Principal{}, database.User{}, fmt.Errorf("couldn't find the user: %w", err)
+	}
+	principal.Roles = []string{user.Role}
+	return principal, user, nil
+}
+
+// requireAuth lets a request through only if it carries a valid access token.
+func (cfg *apiConfig) requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		principal, ok := auth.PrincipalFrom(r.Context())
+		if !ok || principal.TokenType != auth.TokenAccess {
+			respondUnauthorized(w, r, "access token required")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// optionalAuth lets anonymous requests through, but not ones with a token
+// that doesn't validate: a client sending one expects to be recognised.
+func (cfg *apiConfig) optionalAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Context().Value(authFailureKey{}) != nil {
+			respondUnauthorized(w, r, "access token required")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// requireRefreshToken lets a request through only if it carries a refresh
+// token that exists, hasn't expired and hasn't been revoked.
+func (cfg *apiConfig) requireRefreshToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		rtoken, err := auth.GetBearerRefreshToken(r.Header)
+		if err != nil {
+			respondUnauthorized(w, withAuthFailure(r, err), "refresh token required")
+			return
+		}
+
+		rt_info, err := cfg.db.GetRefreshToken(r.Context(), rtoken)
+		if err == nil && rt_info.ExpiresAt.Before(time.Now()) {
+			err = fmt.Errorf("refresh token: %w", auth.ErrTokenExpired)
+		}
+		if err == nil && rt_info.RevokedAt.Valid {
+			err = errors.New("refresh token has been revoked")
+		}
+		if err != nil {
+			respondUnauthorized(w, withAuthFailure(r, err), "refresh token required")
+			return
+		}
+
+		if rec := recorderOf(w); rec != nil {
+			rec.userID = rt_info.UserID.String()
+		}
+		ctx := auth.WithPrincipal(r.Context(), auth.Principal{
+			UserID:    rt_info.UserID,
+			TokenType: auth.TokenRefresh,
+		})
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// principalOf is the principal behind a request that passed requireAuth or
+// requireRefreshToken. Behind optionalAuth it is the zero Principal for
+// anonymous requests.
+func principalOf(r *http.Request) auth.Principal {
+	principal, _ := auth.PrincipalFrom(r.Context())
+	return principal
+}
+
+// authUserOf is the account behind the request's access token, as loaded
+// when the request was authenticated. ok is false for anonymous requests and
+// ones authenticated with a refresh token.
+func authUserOf(r *http.Request) (database.User, bool) {
+	user, ok := r.Context().Value(authUserKey{}).(database.User)
+	return user, ok
+}
+
+// withAuthFailure remembers why the request's token was rejected. A request
+// without any token hasn't failed anything.
+func withAuthFailure(r *http.Request, err error) *http.Request {
+	if errors.Is(err, auth.ErrNoToken) {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), authFailureKey{}, err))
+}
+
+// respondUnauthorized answers 401 with a WWW-Authenticate challenge as in
+// RFC 6750: a bare one when no token was sent, invalid_token otherwise.
+func respondUnauthorized(w http.ResponseWriter, r *http.Request, msg string) {
+
+	challenge := fmt.Sprintf("Bearer realm=%q", authRealm)
+	err, _ := r.Context().Value(authFailureKey{}).(error)
+	if err != nil {
+		challenge += `, error="invalid_token"`
+		msg = "invalid token"
+		if errors.Is(err, auth.ErrTokenExpired) {
+			msg = "token expired"
+		}
+		challenge += fmt.Sprintf(", error_description=%q", msg)
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	code := problem.CodeUnauthorized
+	if err != nil {
+		code = problem.CodeInvalidToken
+	}
+	respondWithProblem(w, problem.New(code, 0, msg), err)
+}
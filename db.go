@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/frozendolphin/Chirpy/internal/database"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var dbTracer = otel.Tracer("github.com/frozendolphin/Chirpy/internal/database")
+
+// instrumentedDB times and traces every query sqlc runs through it. sqlc
+// starts each query with a "-- name: GetUser :one" comment, which names
+// the metric label and the span.
+type instrumentedDB struct {
+	database.DBTX
+	m *metrics
+}
+
+// newQueries returns Queries that record metrics and spans. Use it for
+// transactions too (see apiConfig.withTx), as cfg.db.WithTx(tx) would
+// bypass the instrumentation.
+func newQueries(db database.DBTX, m *metrics) *database.Queries {
+	return database.New(instrumentedDB{DBTX: db, m: m})
+}
+
+func (db instrumentedDB) start(ctx context.Context, query string) (context.Context, func(error)) {
+
+	name := queryName(query)
+	ctx, span := dbTracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system.name", "postgresql"),
+			attribute.String("db.operation.name", name),
+			attribute.String("db.query.text", query),
+		),
+	)
+	start := time.Now()
+
+	finish := func(err error) {
+		db.m.queryDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			db.m.queryErrors.WithLabelValues(name).Inc()
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+	return ctx, finish
+}
+
+func (db instrumentedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, finish := db.start(ctx, query)
+	res, err := db.DBTX.ExecContext(ctx, query, args...)
+	finish(err)
+	return res, err
+}
+
+func (db instrumentedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, finish := db.start(ctx, query)
+	rows, err := db.DBTX.QueryContext(ctx, query, args...)
+	finish(err)
+	return rows, err
+}
+
+func (db instrumentedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, finish := db.start(ctx, query)
+	row := db.DBTX.QueryRowContext(ctx, query, args...)
+	finish(row.Err())
+	return row
+}
+
+func queryName(query string) string {
+
+	rest, ok := strings.CutPrefix(query, "-- name: ")
+	if !ok {
+		return "other"
+	}
+	name, _, _ := strings.Cut(rest, " ")
+	return name
+}
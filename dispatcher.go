@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/frozendolphin/Chirpy/internal/database"
+	"github.com/frozendolphin/Chirpy/internal/webhook"
+	"github.com/google/uuid"
+)
+
+const webhookDeliveryBatchSize = 50
+
+type chirpEvent struct {
+	Id          uuid.UUID  `json:"id"`
+	UserId      uuid.UUID  `json:"user_id"`
+	Body        string     `json:"body,omitempty"`
+	Visibility  string     `json:"visibility,omitempty"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+}
+
+func newChirpEvent(chirp database.Chirp) chirpEvent {
+
+	res := chirpEvent{
+		Id:         chirp.ID,
+		UserId:     chirp.UserID,
+		Body:       chirp.Body,
+		Visibility: chirp.Visibility,
+	}
+	if chirp.PublishedAt.Valid {
+		res.PublishedAt = &chirp.PublishedAt.Time
+	}
+	return res
+}
+
+type followEvent struct {
+	FollowerId uuid.UUID `json:"follower_id"`
+	FolloweeId uuid.UUID `json:"followee_id"`
+}
+
+// enqueueEvent writes the event to the outbox for every endpoint that wants
+// it. Pass the queries of the transaction that made the change, so the event
+// goes out if and only if the change commits. user_ids are the users the
+// event is about; their own endpoints get it.
+func enqueueEvent(ctx context.Context, q *database.Queries, event_type string, data any, user_ids ...uuid.UUID) error {
+
+	event_id := uuid.New()
+	payload, err := json.Marshal(webhook.Event{
+		ID:        event_id,
+		Type:      event_type,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = q.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{
+		EventID:   event_id,
+		EventType: event_type,
+		Payload:   payload,
+		UserIds:   user_ids,
+	})
+	return err
+}
+
+// runWebhookDispatcher sends outbox deliveries that are due. Deliveries are
+// leased by ClaimDueWebhookDeliveries, so replicas don't send the same one
+// at the same time.
+func (cfg *apiConfig) runWebhookDispatcher(ctx context.Context, interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		cfg.dispatchWebhooks(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *apiConfig) dispatchWebhooks(ctx context.Context) {
+
+	for {
+		due, err := cfg.db.ClaimDueWebhookDeliveries(ctx, webhookDeliveryBatchSize)
+		if err != nil {
+			slog.Error("couldn't claim webhook deliveries", "error", err)
+			return
+		}
+
+		var wg sync.WaitGroup
+		for _, delivery := range due {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				cfg.deliverWebhook(ctx, delivery)
+			}()
+		}
+		wg.Wait()
+
+		if len(due) < webhookDeliveryBatchSize {
+			return
+		}
+	}
+}
+
+// deliverWebhook makes one attempt, logs it, and either marks the delivery
+// delivered, schedules the next try, or gives up on it.
+func (cfg *apiConfig) deliverWebhook(ctx context.Context, delivery database.ClaimDueWebhookDeliveriesRow) {
+
+	attempted_at := time.Now().UTC()
+	result := cfg.webhookSender.Send(ctx, delivery.Url, delivery.Secret, delivery.OwnerIsAdmin, delivery.ID, delivery.EventType, delivery.Payload)
+
+	attempt := database.CreateWebhookAttemptParams{
+		DeliveryID:  delivery.ID,
+		AttemptedAt: attempted_at,
+		DurationMs:  int32(result.Duration.Milliseconds()),
+	}
+	if result.StatusCode != 0 {
+		attempt.StatusCode = sql.NullInt32{Int32: int32(result.StatusCode), Valid: true}
+	}
+	if result.Err != nil {
+		attempt.Error = result.Err.Error()
+	}
+
+	err := cfg.db.CreateWebhookAttempt(ctx, attempt)
+	if err != nil {
+		slog.Error("couldn't log webhook attempt", "delivery_id", delivery.ID, "error", err)
+	}
+
+	now := time.Now().UTC()
+	update := database.FinishWebhookAttemptParams{
+		Status:        "delivered",
+		NextAttemptAt: now,
+		DeliveredAt:   sql.NullTime{Time: now, Valid: true},
+		ID:            delivery.ID,
+	}
+	if !result.OK() {
+		update.DeliveredAt = sql.NullTime{}
+		update.Status = "pending"
+		update.NextAttemptAt = now.Add(webhook.Backoff(int(delivery.Attempts)))
+		if delivery.Attempts >= webhook.MaxAttempts {
+			update.Status = "dead"
+		}
+	}
+
+	outcome := update.Status
+	if outcome == "pending" {
+		outcome = "retry"
+	}
+	cfg.metrics.webhookAttempts.WithLabelValues(outcome).Inc()
+
+	err = cfg.db.FinishWebhookAttempt(ctx, update)
+	if err != nil {
+		slog.Error("couldn't update webhook delivery", "delivery_id", delivery.ID, "error", err)
+	}
+}
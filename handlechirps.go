@@ -1,15 +1,19 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
+	"fmt"
 	"net/http"
 	"sort"
-	"strings"
 	"time"
 
-	"github.com/frozendolphin/Chirpy/internal/auth"
+	"github.com/frozendolphin/Chirpy/internal/chirptext"
 	"github.com/frozendolphin/Chirpy/internal/database"
+	"github.com/frozendolphin/Chirpy/internal/filter"
+	"github.com/frozendolphin/Chirpy/internal/jobs"
+	"github.com/frozendolphin/Chirpy/internal/problem"
+	"github.com/frozendolphin/Chirpy/internal/webhook"
 	"github.com/google/uuid"
 )
 
@@ -19,87 +23,328 @@ type chirpInfo struct {
 	UpdatedAt time.Time	`json:"updated_at"`
 	Body      string	`json:"body"`
 	UserId    uuid.UUID `json:"user_id"`
+	Media     []mediaInfo `json:"media"`
+	Visibility string `json:"visibility"`
+	Status    string `json:"status"`
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+	EditedAt  *time.Time `json:"edited_at,omitempty"`
+	Poll      *pollInfo `json:"poll,omitempty"`
+}
+
+// chirpExtras holds what a page of chirps carries from outside the chirps table.
+type chirpExtras struct {
+	media map[uuid.UUID][]mediaInfo
+	polls map[uuid.UUID]*pollInfo
+}
+
+func (cfg *apiConfig) loadChirpExtras(ctx context.Context, chirps []database.Chirp, viewer uuid.UUID) (chirpExtras, error) {
+
+	chirp_ids := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		chirp_ids = append(chirp_ids, chirp.ID)
+	}
+
+	attached, err := cfg.loadChirpMedia(ctx, chirp_ids)
+	if err != nil {
+		return chirpExtras{}, err
+	}
+
+	polls, err := cfg.loadChirpPolls(ctx, chirps, viewer)
+	if err != nil {
+		return chirpExtras{}, err
+	}
+
+	return chirpExtras{media: attached, polls: polls}, nil
+}
+
+func newChirpInfo(chirp database.Chirp, extras chirpExtras) chirpInfo {
+
+	res := chirpInfo {
+		Id: chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		Body: chirp.Body,
+		UserId: chirp.UserID,
+		Media: mediaFor(extras.media, chirp.ID),
+		Visibility: chirp.Visibility,
+		Status: chirp.Status,
+		Poll: extras.polls[chirp.ID],
+	}
+	if chirp.PublishAt.Valid {
+		res.PublishAt = &chirp.PublishAt.Time
+	}
+	if chirp.PublishedAt.Valid {
+		res.PublishedAt = &chirp.PublishedAt.Time
+	}
+	if chirp.EditedAt.Valid {
+		res.EditedAt = &chirp.EditedAt.Time
+	}
+
+	return res
 }
 
 func (cfg *apiConfig) createChirps(w http.ResponseWriter, r *http.Request) {
 	
 	type parameters struct {
 		Body string `json:"body"`
+		MediaIDs []string `json:"media_ids"`
+		Poll *pollParams `json:"poll"`
+		Visibility string `json:"visibility"`
+		PublishAt *time.Time `json:"publish_at"`
+		Draft bool `json:"draft"`
+	}
+
+	user_id := principalOf(r).UserID
+
+	if !cfg.checkStanding(w, r, user_id) {
+		return
 	}
 
-	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
-	err := decoder.Decode(&params)
+	if !cfg.decodeJSON(w, r, &params) {
+		return
+	}
+
+	plan, err := cfg.db.GetUserPlan(r.Context(), user_id)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		respondWithError(w, http.StatusInternalServerError, "db request to get plan Failed", err)
+		return
+	}
+
+	if !cfg.checkChirpQuota(w, r, plan) {
 		return
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
+	cleaned, flags, err := cfg.validateChirp(params.Body, plan)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find token", err)
+		respondWithChirpError(w, err)
 		return
 	}
 
-	user_id, err := auth.ValidateJWT(token, cfg.secret)
+	media_ids, err := parseMediaIDs(params.MediaIDs, int(plan.MaxMediaPerChirp))
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "jwt validation failed", err)
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
 		return
 	}
 
-	cleaned, err := validateChirp(params.Body)
+	if params.PublishAt != nil && !plan.CanSchedule {
+		respondWithError(w, http.StatusForbidden, "your plan doesn't include scheduled chirps", nil)
+		return
+	}
+
+	status, publish_at, err := chirpStatus(params.Draft, params.PublishAt)
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error(), err)
 		return
 	}
 
-	cparams := database.CreateChirpParams{
-		Body: cleaned,
-		UserID: user_id,
+	visibility, err := chirpVisibility(params.Visibility)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	if params.Poll != nil {
+		opens := time.Now().UTC()
+		if publish_at.Valid {
+			opens = publish_at.Time
+		}
+		err = validatePoll(params.Poll, opens)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
 	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
 	
-	chirp, err := cfg.db.CreateChirp(r.Context(), cparams)
+	var chirp database.Chirp
+	if status == "published" {
+		chirp, err = qtx.CreateChirp(r.Context(), database.CreateChirpParams{
+			Body: cleaned,
+			UserID: user_id,
+			Visibility: visibility,
+		})
+	} else {
+		chirp, err = qtx.CreateUnpublishedChirp(r.Context(), database.CreateUnpublishedChirpParams{
+			Body: cleaned,
+			UserID: user_id,
+			Visibility: visibility,
+			Status: status,
+			PublishAt: publish_at,
+		})
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "db request to create chirp Failed", err)
 		return
 	}
 
-	res := chirpInfo {
-		Id: chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body: chirp.Body,
-		UserId: chirp.UserID,
+	for i, m_id := range media_ids {
+		attached, err := qtx.AttachMediaToChirp(r.Context(), database.AttachMediaToChirpParams{
+			ChirpID: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+			Position: int32(i),
+			ID: m_id,
+			UserID: user_id,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "db request to attach media Failed", err)
+			return
+		}
+		if attached == 0 {
+			respondWithError(w, http.StatusBadRequest, "media not found or already attached", nil)
+			return
+		}
+	}
+
+	err = flagChirp(r.Context(), qtx, chirp.ID, flags)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to flag chirp Failed", err)
+		return
+	}
+
+	if params.Poll != nil {
+		err = createPoll(r.Context(), qtx, chirp.ID, *params.Poll)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "db request to create poll Failed", err)
+			return
+		}
+	}
+
+	// scheduled chirps send theirs when they are published
+	switch status {
+	case "published":
+		err = enqueueEvent(r.Context(), qtx, webhook.ChirpCreated, newChirpEvent(chirp), user_id)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "db request to queue webhooks Failed", err)
+			return
+		}
+	case "scheduled":
+		err = enqueueJob(r.Context(), qtx, publishChirpArgs{ChirpID: chirp.ID}, jobs.Options{RunAt: publish_at.Time})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "db request to schedule publishing Failed", err)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't commit transaction", err)
+		return
+	}
+
+	extras, err := cfg.loadChirpExtras(r.Context(), []database.Chirp{chirp}, user_id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to get chirp details Failed", err)
+		return
 	}
 
+	res := newChirpInfo(chirp, extras)
+
 	respondWithJSON(w, http.StatusCreated, res)
 }
 
-func validateChirp(body string) (string, error) {
-	const maxChirpLength = 140
-	if len(body) > maxChirpLength {
-		return "", errors.New("chirp is too long")
+// chirpVisibility validates a requested visibility, defaulting to public.
+func chirpVisibility(v string) (string, error) {
+	switch v {
+	case "":
+		return "public", nil
+	case "public", "followers", "unlisted":
+		return v, nil
+	}
+	return "", errors.New("visibility must be public, followers or unlisted")
+}
+
+var errChirpRejected = errors.New("chirp contains content that isn't allowed")
+
+// validateChirp normalizes a chirp body and checks it against the plan's
+// length limit and the content filter. It returns the body with masked words
+// replaced and the flag rules that matched, which the caller stores for
+// review. Report errors with respondWithChirpError.
+func (cfg *apiConfig) validateChirp(body string, plan database.UserPlan) (string, []filter.Rule, error) {
+
+	body, err := chirptext.Validate(body, int(plan.MaxChirpLength))
+	if err != nil {
+		return "", nil, err
+	}
+
+	res := cfg.filter.Apply(body)
+	if res.Rejected {
+		return "", nil, errChirpRejected
+	}
+
+	return res.Text, res.Flagged(), nil
+}
+
+// respondWithChirpError reports a validateChirp error, with the computed
+// length and limit or the offending character when there is one.
+var chirpProblemCodes = map[chirptext.Code]problem.Code{
+	chirptext.CodeEmpty:            problem.CodeChirpEmpty,
+	chirptext.CodeTooLong:          problem.CodeChirpTooLong,
+	chirptext.CodeInvalidEncoding:  problem.CodeChirpInvalidEncoding,
+	chirptext.CodeInvalidCharacter: problem.CodeChirpInvalidChar,
+}
+
+func respondWithChirpError(w http.ResponseWriter, err error) {
+
+	var text_err *chirptext.Error
+	if errors.As(err, &text_err) {
+		p := problem.New(chirpProblemCodes[text_err.Code], 0, text_err.Error())
+		switch text_err.Code {
+		case chirptext.CodeTooLong:
+			p.With("length", text_err.Length).With("limit", text_err.Limit)
+		case chirptext.CodeInvalidCharacter:
+			p.With("character", fmt.Sprintf("%U", text_err.Char)).With("offset", text_err.Offset)
+		}
+		respondWithProblem(w, p, nil)
+		return
 	}
 
-	badWords := map[string]struct{}{
-		"kerfuffle": {},
-		"sharbert":  {},
-		"fornax":    {},
+	if errors.Is(err, errChirpRejected) {
+		respondWithProblem(w, problem.New(problem.CodeChirpRejected, 0, err.Error()), nil)
+		return
 	}
-	cleaned := getCleanedBody(body, badWords)
-	return cleaned, nil
+
+	respondWithError(w, http.StatusBadRequest, err.Error(), err)
 }
 
-func getCleanedBody(body string, badWords map[string]struct{}) string {
-	words := strings.Split(body, " ")
-	for i, word := range words {
-		loweredWord := strings.ToLower(word)
-		if _, ok := badWords[loweredWord]; ok {
-			words[i] = "****"
+// flagChirp records each flag rule a chirp matched and puts the chirp in
+// the moderation queue.
+func flagChirp(ctx context.Context, q *database.Queries, chirp_id uuid.UUID, flags []filter.Rule) error {
+	if len(flags) == 0 {
+		return nil
+	}
+
+	mod_case, err := q.OpenModerationCase(ctx, chirp_id)
+	if err != nil {
+		return err
+	}
+
+	for _, rule := range flags {
+		err := q.CreateChirpFlag(ctx, database.CreateChirpFlagParams{
+			ChirpID: chirp_id,
+			RuleID: rule.ID,
+		})
+		if err != nil {
+			return err
+		}
+
+		err = q.CreateModerationAction(ctx, database.CreateModerationActionParams{
+			CaseID: uuid.NullUUID{UUID: mod_case.ID, Valid: true},
+			Action: "filter_flag",
+			Note:   "matched filter rule: " + rule.Pattern,
+		})
+		if err != nil {
+			return err
 		}
 	}
-	cleaned := strings.Join(words, " ")
-	return cleaned
+	return nil
 }
 
 func (cfg *apiConfig) getAllChirps(w http.ResponseWriter, r *http.Request) {
@@ -108,6 +353,7 @@ func (cfg *apiConfig) getAllChirps(w http.ResponseWriter, r *http.Request) {
 	var all_chirps []database.Chirp
 	var err error
 
+	viewer := principalOf(r).UserID
 	s := r.URL.Query().Get("author_id")
 	sortchirps := r.URL.Query().Get("sort")
 
@@ -119,7 +365,10 @@ func (cfg *apiConfig) getAllChirps(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		all_chirps, err = cfg.db.GetChirpsFromAuthor(r.Context(), a_id)
+		all_chirps, err = cfg.db.GetChirpsFromAuthor(r.Context(), database.GetChirpsFromAuthorParams{
+			UserID: a_id,
+			ViewerID: viewer,
+		})
 		if err != nil {
 			respondWithError(w, http.StatusNotFound, "chirp cannot be found", err)
 			return
@@ -127,7 +376,7 @@ func (cfg *apiConfig) getAllChirps(w http.ResponseWriter, r *http.Request) {
 
 	} else {
 
-		all_chirps, err = cfg.db.GetAllChirps(r.Context())
+		all_chirps, err = cfg.db.GetAllChirps(r.Context(), viewer)
 		if err != nil {
 			respondWithError(w, http.StatusInternalServerError, "db request to get all chirps Failed", err)
 			return
@@ -136,21 +385,19 @@ func (cfg *apiConfig) getAllChirps(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if sortchirps == "desc" {
-		sort.Slice(all_chirps, func(i, j int) bool {return all_chirps[i].CreatedAt.After(all_chirps[j].CreatedAt) })
+		sort.Slice(all_chirps, func(i, j int) bool {return all_chirps[i].PublishedAt.Time.After(all_chirps[j].PublishedAt.Time) })
+	}
+
+	extras, err := cfg.loadChirpExtras(r.Context(), all_chirps, viewer)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to get chirp details Failed", err)
+		return
 	}
 
 	var chirp_list []chirpInfo
-	var single_chirp chirpInfo
 
 	for _, chirp := range all_chirps {
-		single_chirp = chirpInfo{
-			Id: chirp.ID,
-			CreatedAt: chirp.CreatedAt,
-			UpdatedAt: chirp.UpdatedAt,
-			Body: chirp.Body,
-			UserId: chirp.UserID,
-		}
-		chirp_list = append(chirp_list, single_chirp)
+		chirp_list = append(chirp_list, newChirpInfo(chirp, extras))
 	}
 	
 	respondWithJSON(w, http.StatusOK, chirp_list)
@@ -171,40 +418,141 @@ func (cfg *apiConfig) getAChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	chirp, err := cfg.db.GetAChirp(r.Context(), u_id)
+	viewer := principalOf(r).UserID
+
+	// chirps the viewer may not see are reported as missing so their
+	// existence doesn't leak
+	chirp, err := cfg.db.GetAChirp(r.Context(), database.GetAChirpParams{
+		ID: u_id,
+		ViewerID: viewer,
+	})
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, "couldn't find id in the server", err)
 		return
 	}
 
-	res := chirpInfo {
-		Id: chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body: chirp.Body,
-		UserId: chirp.UserID,
+	extras, err := cfg.loadChirpExtras(r.Context(), []database.Chirp{chirp}, viewer)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to get chirp details Failed", err)
+		return
 	}
 
+	res := newChirpInfo(chirp, extras)
+
 	respondWithJSON(w, http.StatusOK, res)
 } 
 
-func (cfg *apiConfig) deleteAChirp(w http.ResponseWriter, r *http.Request) {
+// editChirp changes the body of a published chirp, which is only allowed
+// within the edit window of the author's plan.
+func (cfg *apiConfig) editChirp(w http.ResponseWriter, r *http.Request) {
 
-	c_id := r.PathValue("chirpID")
-	if c_id == "" {
-		respondWithError(w, http.StatusBadRequest, "no such wildcard in path", nil)
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	user_id := principalOf(r).UserID
+
+	if !cfg.checkStanding(w, r, user_id) {
+		return
+	}
+
+	c_id, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't convert id into uuid", err)
+		return
+	}
+
+	params := parameters{}
+	if !cfg.decodeJSON(w, r, &params) {
+		return
+	}
+
+	chirp, err := cfg.db.GetAChirp(r.Context(), database.GetAChirpParams{
+		ID:       c_id,
+		ViewerID: user_id,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "couldn't find id in the server", err)
+		return
+	}
+
+	if chirp.UserID != user_id {
+		respondWithError(w, http.StatusForbidden, "given chirp is not yours", nil)
+		return
+	}
+
+	plan, err := cfg.db.GetUserPlan(r.Context(), user_id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to get plan Failed", err)
 		return
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
+	window := time.Duration(plan.EditWindowSeconds) * time.Second
+	if window == 0 {
+		respondWithError(w, http.StatusForbidden, "your plan doesn't include editing chirps", nil)
+		return
+	}
+	if time.Since(chirp.PublishedAt.Time) > window {
+		respondWithError(w, http.StatusForbidden, "the edit window for this chirp has passed", nil)
+		return
+	}
+
+	cleaned, flags, err := cfg.validateChirp(params.Body, plan)
+	if err != nil {
+		respondWithChirpError(w, err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	chirp, err = qtx.EditChirp(r.Context(), database.EditChirpParams{
+		Body:   cleaned,
+		ID:     chirp.ID,
+		UserID: user_id,
+	})
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find access token", err)
+		respondWithError(w, http.StatusInternalServerError, "db request to edit chirp Failed", err)
 		return
 	}
 
-	user_id, err := auth.ValidateJWT(token, cfg.secret)
+	err = flagChirp(r.Context(), qtx, chirp.ID, flags)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "jwt validation failed", err)
+		respondWithError(w, http.StatusInternalServerError, "db request to flag chirp Failed", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't commit transaction", err)
+		return
+	}
+
+	extras, err := cfg.loadChirpExtras(r.Context(), []database.Chirp{chirp}, user_id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to get chirp details Failed", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newChirpInfo(chirp, extras))
+}
+
+func (cfg *apiConfig) deleteAChirp(w http.ResponseWriter, r *http.Request) {
+
+	c_id := r.PathValue("chirpID")
+	if c_id == "" {
+		respondWithError(w, http.StatusBadRequest, "no such wildcard in path", nil)
+		return
+	}
+
+	user_id := principalOf(r).UserID
+
+	if !cfg.checkStanding(w, r, user_id) {
 		return
 	}
 
@@ -214,7 +562,10 @@ func (cfg *apiConfig) deleteAChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	chirp, err := cfg.db.GetAChirp(r.Context(), u_id)
+	chirp, err := cfg.db.GetAChirp(r.Context(), database.GetAChirpParams{
+		ID: u_id,
+		ViewerID: user_id,
+	})
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, "couldn't find id in the server", err)
 		return
@@ -225,12 +576,45 @@ func (cfg *apiConfig) deleteAChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = cfg.db.DeleteAChirp(r.Context(), u_id)
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	media, err := qtx.GetMediaForChirps(r.Context(), []uuid.UUID{u_id})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to get media Failed", err)
+		return
+	}
+
+	err = qtx.DeleteAChirp(r.Context(), u_id)
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, "couldn't find id in the server", err)
 		return
 	}
 
+	// the media rows go with the chirp; their files are removed afterwards
+	err = enqueueMediaCleanup(r.Context(), qtx, media)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to queue media cleanup Failed", err)
+		return
+	}
+
+	err = enqueueEvent(r.Context(), qtx, webhook.ChirpDeleted, chirpEvent{Id: chirp.ID, UserId: chirp.UserID}, chirp.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to queue webhooks Failed", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't commit transaction", err)
+		return
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 
 }
\ No newline at end of file
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/frozendolphin/Chirpy/internal/database"
+	"github.com/frozendolphin/Chirpy/internal/filter"
+	"github.com/google/uuid"
+)
+
+const maxFilterPatternLength = 100
+
+type filterRuleInfo struct {
+	Id        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Pattern   string    `json:"pattern"`
+	Action    string    `json:"action"`
+	Enabled   bool      `json:"enabled"`
+}
+
+func newFilterRuleInfo(rule database.FilterRule) filterRuleInfo {
+	return filterRuleInfo{
+		Id:        rule.ID,
+		CreatedAt: rule.CreatedAt,
+		UpdatedAt: rule.UpdatedAt,
+		Pattern:   rule.Pattern,
+		Action:    rule.Action,
+		Enabled:   rule.Enabled,
+	}
+}
+
+// reloadFilter recompiles the content filter from the enabled rules.
+func (cfg *apiConfig) reloadFilter(ctx context.Context) error {
+
+	rows, err := cfg.db.ListEnabledFilterRules(ctx)
+	if err != nil {
+		return err
+	}
+
+	rules := make([]filter.Rule, 0, len(rows))
+	for _, row := range rows {
+		rules = append(rules, filter.Rule{
+			ID:      row.ID,
+			Pattern: row.Pattern,
+			Action:  filter.Action(row.Action),
+		})
+	}
+
+	cfg.filter.Load(rules)
+	return nil
+}
+
+// runFilterReloader periodically picks up rule changes made through other
+// replicas. Changes made through this one are applied immediately.
+func (cfg *apiConfig) runFilterReloader(ctx context.Context, interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := cfg.reloadFilter(ctx)
+		if err != nil {
+			slog.Error("couldn't reload filter rules", "error", err)
+		}
+	}
+}
+
+type filterRuleParams struct {
+	Pattern string `json:"pattern"`
+	Action  string `json:"action"`
+	Enabled *bool  `json:"enabled"`
+}
+
+func (p *filterRuleParams) validate() error {
+
+	p.Pattern = strings.TrimSpace(p.Pattern)
+	if p.Pattern == "" {
+		return errors.New("pattern is required")
+	}
+	if utf8.RuneCountInString(p.Pattern) > maxFilterPatternLength {
+		return errors.New("pattern is too long")
+	}
+	if !filter.Action(p.Action).Valid() {
+		return errors.New("action must be mask, reject or flag")
+	}
+	return nil
+}
+
+func (cfg *apiConfig) listFilterRules(w http.ResponseWriter, r *http.Request) {
+
+	_, ok := cfg.requireRole(w, r, "admin")
+	if !ok {
+		return
+	}
+
+	rules, err := cfg.db.ListFilterRules(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to list filter rules Failed", err)
+		return
+	}
+
+	res := []filterRuleInfo{}
+	for _, rule := range rules {
+		res = append(res, newFilterRuleInfo(rule))
+	}
+
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+func (cfg *apiConfig) createFilterRule(w http.ResponseWriter, r *http.Request) {
+
+	_, ok := cfg.requireRole(w, r, "admin")
+	if !ok {
+		return
+	}
+
+	params := filterRuleParams{}
+	if !cfg.decodeJSON(w, r, &params) {
+		return
+	}
+
+	err := params.validate()
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	enabled := true
+	if params.Enabled != nil {
+		enabled = *params.Enabled
+	}
+
+	rule, err := cfg.db.CreateFilterRule(r.Context(), database.CreateFilterRuleParams{
+		Pattern: params.Pattern,
+		Action:  params.Action,
+		Enabled: enabled,
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "a rule with this pattern already exists", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to create filter rule Failed", err)
+		return
+	}
+
+	err = cfg.reloadFilter(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't reload filter rules", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, newFilterRuleInfo(rule))
+}
+
+func (cfg *apiConfig) updateFilterRule(w http.ResponseWriter, r *http.Request) {
+
+	_, ok := cfg.requireRole(w, r, "admin")
+	if !ok {
+		return
+	}
+
+	rule_id, err := uuid.Parse(r.PathValue("ruleID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't convert id into uuid", err)
+		return
+	}
+
+	params := filterRuleParams{}
+	if !cfg.decodeJSON(w, r, &params) {
+		return
+	}
+
+	err = params.validate()
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	if params.Enabled == nil {
+		respondWithError(w, http.StatusBadRequest, "enabled is required", nil)
+		return
+	}
+
+	rule, err := cfg.db.UpdateFilterRule(r.Context(), database.UpdateFilterRuleParams{
+		Pattern: params.Pattern,
+		Action:  params.Action,
+		Enabled: *params.Enabled,
+		ID:      rule_id,
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "a rule with this pattern already exists", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "couldn't find the filter rule", err)
+		return
+	}
+
+	err = cfg.reloadFilter(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't reload filter rules", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newFilterRuleInfo(rule))
+}
+
+func (cfg *apiConfig) deleteFilterRule(w http.ResponseWriter, r *http.Request) {
+
+	_, ok := cfg.requireRole(w, r, "admin")
+	if !ok {
+		return
+	}
+
+	rule_id, err := uuid.Parse(r.PathValue("ruleID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't convert id into uuid", err)
+		return
+	}
+
+	deleted, err := cfg.db.DeleteFilterRule(r.Context(), rule_id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to delete filter rule Failed", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "couldn't find the filter rule", nil)
+		return
+	}
+
+	err = cfg.reloadFilter(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't reload filter rules", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,39 +1,47 @@
 package main
 
 import (
-	"encoding/json"
 	"net/http"
 	"time"
 
 	"github.com/frozendolphin/Chirpy/internal/auth"
 	"github.com/frozendolphin/Chirpy/internal/database"
+	"github.com/frozendolphin/Chirpy/internal/problem"
+	"github.com/frozendolphin/Chirpy/internal/validate"
 )
 
 func (cfg *apiConfig) loginUser(w http.ResponseWriter, r *http.Request) {
 
 	type reqBody struct {
-		Password string `json:"password"`
-		Email string `json:"email"`
+		Password string `json:"password" validate:"required"`
+		Email string `json:"email" validate:"required"`
 	}
 
 	params := reqBody {}
 
-	decoder := json.NewDecoder(r.Body)
-	err := decoder.Decode(&params)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+	if !cfg.decodeJSON(w, r, &params) {
 		return
 	}
+	params.Email = validate.NormalizeEmail(params.Email)
 
 	user, err := cfg.db.GetUserByEmail(r.Context(), params.Email)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
+		cfg.metrics.logins.WithLabelValues("failure").Inc()
+		respondWithProblem(w, problem.New(problem.CodeInvalidCredentials, 0, "Incorrect email or password"), err)
 		return
 	}
 
-	err = auth.CheckPasswordHash(params.Password, user.HashedPassword)
+	err = auth.CheckPasswordHash(r.Context(), params.Password, user.HashedPassword)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password", err)
+		cfg.metrics.logins.WithLabelValues("failure").Inc()
+		respondWithProblem(w, problem.New(problem.CodeInvalidCredentials, 0, "Incorrect email or password"), err)
+		return
+	}
+
+	standing := standingOf(user, time.Now().UTC())
+	if standing.restricted() {
+		cfg.metrics.logins.WithLabelValues("restricted").Inc()
+		respondRestricted(w, standing)
 		return
 	}
 
@@ -62,15 +70,26 @@ func (cfg *apiConfig) loginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	is_red, err := cfg.isChirpyRed(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to get plan Failed", err)
+		return
+	}
+
 	res := usersInfo {
 		Id: user.ID,
 		CreatedAt: user.CreatedAt,
 		UpdatedAt: user.UpdatedAt,
 		Email: user.Email,
+		Handle: user.Handle,
+		DisplayName: user.DisplayName,
+		Bio: user.Bio,
+		AvatarUrl: user.AvatarUrl,
 		Token: token,
 		RefreshToken: rt.Token,
-		IsChiryRed: user.IsChirpyRed,
+		IsChiryRed: is_red,
 	}
 
+	cfg.metrics.logins.WithLabelValues("success").Inc()
 	respondWithJSON(w, http.StatusOK, res)
 }
\ No newline at end of file
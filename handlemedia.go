@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/frozendolphin/Chirpy/internal/blob"
+	"github.com/frozendolphin/Chirpy/internal/database"
+	"github.com/frozendolphin/Chirpy/internal/media"
+	"github.com/frozendolphin/Chirpy/internal/problem"
+	"github.com/google/uuid"
+)
+
+type mediaInfo struct {
+	Id           uuid.UUID `json:"id"`
+	ContentType  string    `json:"content_type"`
+	Width        int32     `json:"width"`
+	Height       int32     `json:"height"`
+	Url          string    `json:"url"`
+	ThumbnailUrl string    `json:"thumbnail_url"`
+}
+
+func newMediaInfo(m database.Medium) mediaInfo {
+	return mediaInfo{
+		Id:           m.ID,
+		ContentType:  m.ContentType,
+		Width:        m.Width,
+		Height:       m.Height,
+		Url:          "/api/media/" + m.ID.String(),
+		ThumbnailUrl: "/api/media/" + m.ID.String() + "/thumbnail",
+	}
+}
+
+func (cfg *apiConfig) uploadMedia(w http.ResponseWriter, r *http.Request) {
+
+	user_id := principalOf(r).UserID
+
+	if !cfg.checkStanding(w, r, user_id) {
+		return
+	}
+
+	// uploads are stored before they are attached, so cap how many can wait
+	pending, err := cfg.db.CountPendingMedia(r.Context(), user_id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to count uploads Failed", err)
+		return
+	}
+	if pending >= int64(cfg.mediaMaxPending) {
+		msg := fmt.Sprintf("you have %d uploads not attached to a chirp; attach or wait for them to expire first", pending)
+		respondWithProblem(w, problem.New(problem.CodeQuotaExceeded, 0, msg).With("limit", cfg.mediaMaxPending), nil)
+		return
+	}
+
+	// leave some room for the multipart framing around the file
+	r.Body = http.MaxBytesReader(w, r.Body, cfg.mediaMaxBytes+64*1024)
+
+	data, err := readUploadedFile(r, "file", cfg.mediaMaxBytes)
+	var maxBytesErr *http.MaxBytesError
+	if errors.Is(err, errFileTooLarge) || errors.As(err, &maxBytesErr) {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "file is too large", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't read the uploaded file", err)
+		return
+	}
+
+	processed, err := media.Process(data, media.DefaultOptions)
+	if errors.Is(err, media.ErrUnsupportedType) {
+		respondWithError(w, http.StatusUnsupportedMediaType, "only jpeg, png, gif and webp images are supported", err)
+		return
+	}
+	if errors.Is(err, media.ErrTooManyPixels) {
+		respondWithError(w, http.StatusBadRequest, "image dimensions are too large", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't process the image", err)
+		return
+	}
+
+	id := uuid.New()
+	original_key := "media/" + id.String() + "/original"
+	thumbnail_key := "media/" + id.String() + "/thumbnail"
+
+	err = cfg.blobs.Put(r.Context(), original_key, bytes.NewReader(processed.Original.Data), processed.Original.ContentType)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't store the image", err)
+		return
+	}
+
+	err = cfg.blobs.Put(r.Context(), thumbnail_key, bytes.NewReader(processed.Thumbnail.Data), processed.Thumbnail.ContentType)
+	if err != nil {
+		cfg.blobs.Delete(context.WithoutCancel(r.Context()), original_key)
+		respondWithError(w, http.StatusInternalServerError, "couldn't store the thumbnail", err)
+		return
+	}
+
+	m, err := cfg.db.CreateMedia(r.Context(), database.CreateMediaParams{
+		ID:           id,
+		UserID:       user_id,
+		ContentType:  processed.Original.ContentType,
+		Width:        int32(processed.Original.Width),
+		Height:       int32(processed.Original.Height),
+		SizeBytes:    int64(len(processed.Original.Data)),
+		StorageKey:   original_key,
+		ThumbnailKey: thumbnail_key,
+	})
+	if err != nil {
+		cfg.blobs.Delete(context.WithoutCancel(r.Context()), original_key)
+		cfg.blobs.Delete(context.WithoutCancel(r.Context()), thumbnail_key)
+		respondWithError(w, http.StatusInternalServerError, "db request to create media Failed", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, newMediaInfo(m))
+}
+
+var errFileTooLarge = errors.New("file is too large")
+
+// readUploadedFile returns the contents of the named file field of a
+// multipart/form-data request, reading at most limit bytes.
+func readUploadedFile(r *http.Request, field string, limit int64) ([]byte, error) {
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, errors.New("no " + field + " field in form")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if part.FormName() != field {
+			part.Close()
+			continue
+		}
+
+		data, err := io.ReadAll(io.LimitReader(part, limit+1))
+		part.Close()
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(data)) > limit {
+			return nil, errFileTooLarge
+		}
+		return data, nil
+	}
+}
+
+func (cfg *apiConfig) getMediaOriginal(w http.ResponseWriter, r *http.Request) {
+	cfg.serveMedia(w, r, func(m database.Medium) string { return m.StorageKey })
+}
+
+func (cfg *apiConfig) getMediaThumbnail(w http.ResponseWriter, r *http.Request) {
+	cfg.serveMedia(w, r, func(m database.Medium) string { return m.ThumbnailKey })
+}
+
+func (cfg *apiConfig) serveMedia(w http.ResponseWriter, r *http.Request, key func(database.Medium) string) {
+
+	m_id, err := uuid.Parse(r.PathValue("mediaID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't convert id into uuid", err)
+		return
+	}
+
+	m, err := cfg.db.GetMedia(r.Context(), m_id)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "couldn't find media", err)
+		return
+	}
+
+	// media follows the visibility of its chirp; until it is attached to a
+	// published one only the uploader can see it
+	cache_control := "private, max-age=3600"
+	viewer := principalOf(r).UserID
+	if m.UserID != viewer {
+		if !m.ChirpID.Valid {
+			respondWithError(w, http.StatusNotFound, "couldn't find media", nil)
+			return
+		}
+		chirp, err := cfg.db.GetAChirp(r.Context(), database.GetAChirpParams{
+			ID:       m.ChirpID.UUID,
+			ViewerID: viewer,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, "couldn't find media", err)
+			return
+		}
+		if chirp.Visibility != "followers" {
+			cache_control = "public, max-age=31536000, immutable"
+		}
+	}
+
+	rc, err := cfg.blobs.Get(r.Context(), key(m))
+	if errors.Is(err, blob.ErrNotFound) {
+		respondWithError(w, http.StatusNotFound, "couldn't find media", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't read media", err)
+		return
+	}
+	defer rc.Close()
+
+	// thumbnails share the content type of their original
+	w.Header().Set("Content-Type", m.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", cache_control)
+	w.WriteHeader(http.StatusOK)
+	io.Copy(w, rc)
+}
+
+// loadChirpMedia fetches the attachments of the given chirps keyed by chirp id.
+func (cfg *apiConfig) loadChirpMedia(ctx context.Context, chirp_ids []uuid.UUID) (map[uuid.UUID][]mediaInfo, error) {
+
+	attached := map[uuid.UUID][]mediaInfo{}
+	if len(chirp_ids) == 0 {
+		return attached, nil
+	}
+
+	rows, err := cfg.db.GetMediaForChirps(ctx, chirp_ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range rows {
+		attached[m.ChirpID.UUID] = append(attached[m.ChirpID.UUID], newMediaInfo(m))
+	}
+
+	return attached, nil
+}
+
+func mediaFor(attached map[uuid.UUID][]mediaInfo, chirp_id uuid.UUID) []mediaInfo {
+	if m, ok := attached[chirp_id]; ok {
+		return m
+	}
+	return []mediaInfo{}
+}
+
+// parseMediaIDs validates the media ids a chirp wants to attach, at most
+// max of them.
+func parseMediaIDs(raw []string, max int) ([]uuid.UUID, error) {
+
+	if len(raw) > max {
+		return nil, fmt.Errorf("your plan allows at most %d attachments per chirp", max)
+	}
+
+	ids := make([]uuid.UUID, 0, len(raw))
+	seen := map[uuid.UUID]struct{}{}
+	for _, s := range raw {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			return nil, errors.New("media id cannot be parsed to uuid format")
+		}
+		if _, ok := seen[id]; ok {
+			return nil, errors.New("media ids must be unique")
+		}
+		seen[id] = struct{}{}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
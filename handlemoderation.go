@@ -0,0 +1,517 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/frozendolphin/Chirpy/internal/auth"
+	"github.com/frozendolphin/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const defaultSuspendDays = 7
+
+var moderationStatuses = map[string]struct{}{
+	"open":     {},
+	"claimed":  {},
+	"resolved": {},
+}
+
+type moderationCaseInfo struct {
+	Id         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	ChirpId    uuid.UUID  `json:"chirp_id"`
+	Status     string     `json:"status"`
+	ClaimedBy  *uuid.UUID `json:"claimed_by"`
+	ClaimedAt  *time.Time `json:"claimed_at"`
+	Resolution string     `json:"resolution,omitempty"`
+	ResolvedBy *uuid.UUID `json:"resolved_by"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+}
+
+func newModerationCaseInfo(c database.ModerationCase) moderationCaseInfo {
+
+	info := moderationCaseInfo{
+		Id:         c.ID,
+		CreatedAt:  c.CreatedAt,
+		UpdatedAt:  c.UpdatedAt,
+		ChirpId:    c.ChirpID,
+		Status:     c.Status,
+		Resolution: c.Resolution.String,
+	}
+	if c.ClaimedBy.Valid {
+		info.ClaimedBy = &c.ClaimedBy.UUID
+	}
+	if c.ClaimedAt.Valid {
+		info.ClaimedAt = &c.ClaimedAt.Time
+	}
+	if c.ResolvedBy.Valid {
+		info.ResolvedBy = &c.ResolvedBy.UUID
+	}
+	if c.ResolvedAt.Valid {
+		info.ResolvedAt = &c.ResolvedAt.Time
+	}
+	return info
+}
+
+type moderationQueueItem struct {
+	moderationCaseInfo
+	// the chirp fields are empty once the chirp has been deleted
+	ChirpBody    string     `json:"chirp_body"`
+	AuthorId     *uuid.UUID `json:"author_id"`
+	ChirpHidden  bool       `json:"chirp_hidden"`
+	ChirpDeleted bool       `json:"chirp_deleted"`
+	ReportCount  int64      `json:"report_count"`
+}
+
+type reportInfo struct {
+	Id         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	ReporterId uuid.UUID `json:"reporter_id"`
+	Reason     string    `json:"reason"`
+	Details    string    `json:"details"`
+}
+
+type moderationActionInfo struct {
+	Id            uuid.UUID  `json:"id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	CaseId        *uuid.UUID `json:"case_id"`
+	SubjectUserId *uuid.UUID `json:"subject_user_id"`
+	ModeratorId   *uuid.UUID `json:"moderator_id"`
+	Action        string     `json:"action"`
+	Note          string     `json:"note"`
+}
+
+func newModerationActionInfo(a database.ModerationAction) moderationActionInfo {
+
+	info := moderationActionInfo{
+		Id:        a.ID,
+		CreatedAt: a.CreatedAt,
+		Action:    a.Action,
+		Note:      a.Note,
+	}
+	if a.CaseID.Valid {
+		info.CaseId = &a.CaseID.UUID
+	}
+	if a.SubjectUserID.Valid {
+		info.SubjectUserId = &a.SubjectUserID.UUID
+	}
+	if a.ModeratorID.Valid {
+		info.ModeratorId = &a.ModeratorID.UUID
+	}
+	return info
+}
+
+func (cfg *apiConfig) reportChirp(w http.ResponseWriter, r *http.Request) {
+
+	type parameters struct {
+		Reason  string `json:"reason" validate:"required,oneof=spam harassment hate violence misinformation other"`
+		Details string `json:"details" validate:"max=500"`
+	}
+
+	user_id := principalOf(r).UserID
+
+	if !cfg.checkStanding(w, r, user_id) {
+		return
+	}
+
+	c_id, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't convert id into uuid", err)
+		return
+	}
+
+	params := parameters{}
+	if !cfg.decodeJSON(w, r, &params) {
+		return
+	}
+
+	chirp, err := cfg.db.GetAChirp(r.Context(), database.GetAChirpParams{
+		ID:       c_id,
+		ViewerID: user_id,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "couldn't find id in the server", err)
+		return
+	}
+
+	if chirp.UserID == user_id {
+		respondWithError(w, http.StatusBadRequest, "you can't report your own chirp", nil)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	mod_case, err := qtx.OpenModerationCase(r.Context(), chirp.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to open moderation case Failed", err)
+		return
+	}
+
+	created, err := qtx.CreateReport(r.Context(), database.CreateReportParams{
+		CaseID:     mod_case.ID,
+		ChirpID:    chirp.ID,
+		ReporterID: user_id,
+		Reason:     params.Reason,
+		Details:    params.Details,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to create report Failed", err)
+		return
+	}
+	if created == 0 {
+		respondWithError(w, http.StatusConflict, "you have already reported this chirp", nil)
+		return
+	}
+
+	err = cfg.autoHideChirp(r.Context(), qtx, mod_case)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to hide chirp Failed", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't commit transaction", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// autoHideChirp hides the chirp of a case once enough different users have
+// reported it. A threshold of 0 turns automatic hiding off.
+func (cfg *apiConfig) autoHideChirp(ctx context.Context, qtx *database.Queries, mod_case database.ModerationCase) error {
+
+	if cfg.reportHideThreshold <= 0 {
+		return nil
+	}
+
+	reporters, err := qtx.CountCaseReporters(ctx, mod_case.ID)
+	if err != nil {
+		return err
+	}
+	if reporters < int64(cfg.reportHideThreshold) {
+		return nil
+	}
+
+	hidden, err := qtx.HideChirp(ctx, mod_case.ChirpID)
+	if err != nil {
+		return err
+	}
+	if hidden == 0 {
+		return nil
+	}
+
+	return qtx.CreateModerationAction(ctx, database.CreateModerationActionParams{
+		CaseID: uuid.NullUUID{UUID: mod_case.ID, Valid: true},
+		Action: "auto_hide",
+		Note:   fmt.Sprintf("hidden after reports from %d users", reporters),
+	})
+}
+
+func (cfg *apiConfig) listModerationCases(w http.ResponseWriter, r *http.Request) {
+
+	_, ok := cfg.requireRole(w, r, "moderator", "admin")
+	if !ok {
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = "open"
+	}
+	if _, ok := moderationStatuses[status]; !ok {
+		respondWithError(w, http.StatusBadRequest, "status must be open, claimed or resolved", nil)
+		return
+	}
+
+	rows, err := cfg.db.ListModerationCases(r.Context(), status)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to list moderation cases Failed", err)
+		return
+	}
+
+	res := []moderationQueueItem{}
+	for _, row := range rows {
+		item := moderationQueueItem{
+			moderationCaseInfo: newModerationCaseInfo(database.ModerationCase{
+				ID:         row.ID,
+				CreatedAt:  row.CreatedAt,
+				UpdatedAt:  row.UpdatedAt,
+				ChirpID:    row.ChirpID,
+				Status:     row.Status,
+				ClaimedBy:  row.ClaimedBy,
+				ClaimedAt:  row.ClaimedAt,
+				Resolution: row.Resolution,
+				ResolvedBy: row.ResolvedBy,
+				ResolvedAt: row.ResolvedAt,
+			}),
+			ChirpBody:    row.ChirpBody.String,
+			ChirpHidden:  row.ChirpHiddenAt.Valid,
+			ChirpDeleted: !row.ChirpBody.Valid,
+			ReportCount:  row.ReportCount,
+		}
+		if row.AuthorID.Valid {
+			item.AuthorId = &row.AuthorID.UUID
+		}
+		res = append(res, item)
+	}
+
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+func (cfg *apiConfig) getModerationCase(w http.ResponseWriter, r *http.Request) {
+
+	type response struct {
+		moderationCaseInfo
+		Reports []reportInfo           `json:"reports"`
+		Actions []moderationActionInfo `json:"actions"`
+	}
+
+	_, ok := cfg.requireRole(w, r, "moderator", "admin")
+	if !ok {
+		return
+	}
+
+	case_id, err := uuid.Parse(r.PathValue("caseID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't convert id into uuid", err)
+		return
+	}
+
+	mod_case, err := cfg.db.GetModerationCase(r.Context(), case_id)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "couldn't find the moderation case", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to get moderation case Failed", err)
+		return
+	}
+
+	reports, err := cfg.db.GetCaseReports(r.Context(), case_id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to get reports Failed", err)
+		return
+	}
+
+	actions, err := cfg.db.ListModerationActions(r.Context(), uuid.NullUUID{UUID: case_id, Valid: true})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to get moderation actions Failed", err)
+		return
+	}
+
+	res := response{
+		moderationCaseInfo: newModerationCaseInfo(mod_case),
+		Reports:            []reportInfo{},
+		Actions:            []moderationActionInfo{},
+	}
+	for _, report := range reports {
+		res.Reports = append(res.Reports, reportInfo{
+			Id:         report.ID,
+			CreatedAt:  report.CreatedAt,
+			ReporterId: report.ReporterID,
+			Reason:     report.Reason,
+			Details:    report.Details,
+		})
+	}
+	for _, action := range actions {
+		res.Actions = append(res.Actions, newModerationActionInfo(action))
+	}
+
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+func (cfg *apiConfig) claimModerationCase(w http.ResponseWriter, r *http.Request) {
+
+	moderator, ok := cfg.requireRole(w, r, "moderator", "admin")
+	if !ok {
+		return
+	}
+
+	case_id, err := uuid.Parse(r.PathValue("caseID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't convert id into uuid", err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	mod_case, err := qtx.ClaimModerationCase(r.Context(), database.ClaimModerationCaseParams{
+		ClaimedBy: uuid.NullUUID{UUID: moderator.ID, Valid: true},
+		ID:        case_id,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusConflict, "case doesn't exist or isn't open", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to claim moderation case Failed", err)
+		return
+	}
+
+	err = qtx.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+		CaseID:      uuid.NullUUID{UUID: mod_case.ID, Valid: true},
+		ModeratorID: uuid.NullUUID{UUID: moderator.ID, Valid: true},
+		Action:      "claim",
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to record moderation action Failed", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't commit transaction", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newModerationCaseInfo(mod_case))
+}
+
+func (cfg *apiConfig) resolveModerationCase(w http.ResponseWriter, r *http.Request) {
+
+	type parameters struct {
+		Action      string `json:"action" validate:"required,oneof=dismiss hide_chirp suspend_user"`
+		Note        string `json:"note" validate:"max=1000"`
+		SuspendDays int    `json:"suspend_days" validate:"min=0,max=365"`
+	}
+
+	moderator, ok := cfg.requireRole(w, r, "moderator", "admin")
+	if !ok {
+		return
+	}
+
+	case_id, err := uuid.Parse(r.PathValue("caseID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't convert id into uuid", err)
+		return
+	}
+
+	params := parameters{}
+	if !cfg.decodeJSON(w, r, &params) {
+		return
+	}
+
+	if params.Action == "suspend_user" && params.SuspendDays == 0 {
+		params.SuspendDays = defaultSuspendDays
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	mod_case, err := qtx.ResolveModerationCase(r.Context(), database.ResolveModerationCaseParams{
+		Resolution: sql.NullString{String: params.Action, Valid: true},
+		ResolvedBy: uuid.NullUUID{UUID: moderator.ID, Valid: true},
+		ID:         case_id,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusConflict, "case must be claimed by you before it can be resolved", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to resolve moderation case Failed", err)
+		return
+	}
+
+	// dismissing also undoes an automatic hide
+	var subject uuid.NullUUID
+	switch params.Action {
+	case "dismiss":
+		err = qtx.UnhideChirp(r.Context(), mod_case.ChirpID)
+	case "hide_chirp":
+		_, err = qtx.HideChirp(r.Context(), mod_case.ChirpID)
+	case "suspend_user":
+		subject.UUID, err = suspendChirpAuthor(r.Context(), qtx, mod_case.ChirpID, moderator.Role, params.SuspendDays, params.Note)
+		subject.Valid = err == nil
+	}
+	if errors.Is(err, errOutranked) {
+		respondWithError(w, http.StatusForbidden, "you can only suspend users below your role", err)
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusConflict, "the chirp has been deleted, so the case can't suspend its author", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to apply moderation action Failed", err)
+		return
+	}
+
+	err = qtx.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+		CaseID:        uuid.NullUUID{UUID: mod_case.ID, Valid: true},
+		SubjectUserID: subject,
+		ModeratorID:   uuid.NullUUID{UUID: moderator.ID, Valid: true},
+		Action:        params.Action,
+		Note:          params.Note,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to record moderation action Failed", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't commit transaction", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newModerationCaseInfo(mod_case))
+}
+
+// errOutranked means the moderator's role isn't above the user's.
+var errOutranked = errors.New("user's role isn't below the moderator's")
+
+// suspendChirpAuthor hides the chirp and suspends the user who wrote it,
+// returning that user's id. Authors whose role isn't below moderator_role
+// are left alone with errOutranked.
+func suspendChirpAuthor(ctx context.Context, qtx *database.Queries, chirp_id uuid.UUID, moderator_role string, days int, reason string) (uuid.UUID, error) {
+
+	chirp, err := qtx.GetChirpByID(ctx, chirp_id)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	author, err := qtx.GetUserByID(ctx, chirp.UserID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if !auth.Outranks(moderator_role, author.Role) {
+		return uuid.Nil, errOutranked
+	}
+
+	_, err = qtx.HideChirp(ctx, chirp.ID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	err = qtx.SuspendUser(ctx, database.SuspendUserParams{
+		SuspendedUntil:   sql.NullTime{Time: time.Now().UTC().AddDate(0, 0, days), Valid: true},
+		SuspensionReason: reason,
+		ID:               chirp.UserID,
+	})
+	return chirp.UserID, err
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/frozendolphin/Chirpy/internal/database"
+	"github.com/frozendolphin/Chirpy/internal/problem"
+)
+
+type planInfo struct {
+	Id                string `json:"id"`
+	Name              string `json:"name" validate:"required"`
+	MaxChirpLength    int32  `json:"max_chirp_length" validate:"min=1"`
+	EditWindowSeconds int32  `json:"edit_window_seconds" validate:"min=0"`
+	ChirpsPerHour     int32  `json:"chirps_per_hour" validate:"min=1"`
+	MaxMediaPerChirp  int32  `json:"max_media_per_chirp" validate:"min=0"`
+	CanSchedule       bool   `json:"can_schedule"`
+	Badge             string `json:"badge"`
+}
+
+func newPlanInfo(p database.Plan) planInfo {
+	return planInfo{
+		Id:                p.ID,
+		Name:              p.Name,
+		MaxChirpLength:    p.MaxChirpLength,
+		EditWindowSeconds: p.EditWindowSeconds,
+		ChirpsPerHour:     p.ChirpsPerHour,
+		MaxMediaPerChirp:  p.MaxMediaPerChirp,
+		CanSchedule:       p.CanSchedule,
+		Badge:             p.Badge,
+	}
+}
+
+// checkChirpQuota refuses a new chirp once the user has posted as many in
+// the last hour as their plan allows. It writes the error response itself
+// when it returns false.
+func (cfg *apiConfig) checkChirpQuota(w http.ResponseWriter, r *http.Request, plan database.UserPlan) bool {
+
+	posted, err := cfg.db.CountChirpsSince(r.Context(), database.CountChirpsSinceParams{
+		UserID:    plan.UserID,
+		CreatedAt: time.Now().UTC().Add(-time.Hour),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to count chirps Failed", err)
+		return false
+	}
+
+	if posted >= int64(plan.ChirpsPerHour) {
+		msg := fmt.Sprintf("your plan allows %d chirps per hour", plan.ChirpsPerHour)
+		respondWithProblem(w, problem.New(problem.CodeQuotaExceeded, 0, msg), nil)
+		return false
+	}
+	return true
+}
+
+func (cfg *apiConfig) listPlans(w http.ResponseWriter, r *http.Request) {
+
+	plans, err := cfg.db.ListPlans(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to list plans Failed", err)
+		return
+	}
+
+	res := []planInfo{}
+	for _, plan := range plans {
+		res = append(res, newPlanInfo(plan))
+	}
+
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+func (cfg *apiConfig) updatePlan(w http.ResponseWriter, r *http.Request) {
+
+	_, ok := cfg.requireRole(w, r, "admin")
+	if !ok {
+		return
+	}
+
+	params := planInfo{}
+	if !cfg.decodeJSON(w, r, &params) {
+		return
+	}
+
+	params.Name = strings.TrimSpace(params.Name)
+
+	plan, err := cfg.db.UpdatePlan(r.Context(), database.UpdatePlanParams{
+		Name:              params.Name,
+		MaxChirpLength:    params.MaxChirpLength,
+		EditWindowSeconds: params.EditWindowSeconds,
+		ChirpsPerHour:     params.ChirpsPerHour,
+		MaxMediaPerChirp:  params.MaxMediaPerChirp,
+		CanSchedule:       params.CanSchedule,
+		Badge:             params.Badge,
+		ID:                r.PathValue("planID"),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "couldn't find the plan", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to update plan Failed", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newPlanInfo(plan))
+}
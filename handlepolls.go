@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/frozendolphin/Chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	minPollOptions      = 2
+	maxPollOptions      = 4
+	maxPollOptionLength = 25
+	maxPollDuration     = 7 * 24 * time.Hour
+)
+
+type pollParams struct {
+	Options        []string  `json:"options"`
+	ClosesAt       time.Time `json:"closes_at"`
+	MultipleChoice bool      `json:"multiple_choice"`
+	HideResults    bool      `json:"hide_results"`
+}
+
+type pollOptionInfo struct {
+	Id    uuid.UUID `json:"id"`
+	Text  string    `json:"text"`
+	Votes *int64    `json:"votes,omitempty"`
+}
+
+type pollInfo struct {
+	Id             uuid.UUID        `json:"id"`
+	ClosesAt       time.Time        `json:"closes_at"`
+	Closed         bool             `json:"closed"`
+	MultipleChoice bool             `json:"multiple_choice"`
+	ResultsHidden  bool             `json:"results_hidden"`
+	VoterCount     *int64           `json:"voter_count,omitempty"`
+	Options        []pollOptionInfo `json:"options"`
+	ViewerVotes    []uuid.UUID      `json:"viewer_votes"`
+}
+
+// validatePoll checks a poll for a chirp that goes live at opens.
+func validatePoll(p *pollParams, opens time.Time) error {
+
+	if len(p.Options) < minPollOptions || len(p.Options) > maxPollOptions {
+		return fmt.Errorf("a poll needs %d to %d options", minPollOptions, maxPollOptions)
+	}
+
+	seen := map[string]struct{}{}
+	for i, option := range p.Options {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			return errors.New("poll options can't be empty")
+		}
+		if utf8.RuneCountInString(option) > maxPollOptionLength {
+			return fmt.Errorf("poll options can be at most %d characters", maxPollOptionLength)
+		}
+		key := strings.ToLower(option)
+		if _, ok := seen[key]; ok {
+			return errors.New("poll options must be unique")
+		}
+		seen[key] = struct{}{}
+		p.Options[i] = option
+	}
+
+	if !p.ClosesAt.After(opens) {
+		return errors.New("poll closes_at must be after the chirp is published")
+	}
+	if p.ClosesAt.Sub(opens) > maxPollDuration {
+		return errors.New("polls can stay open for at most 7 days")
+	}
+
+	return nil
+}
+
+func createPoll(ctx context.Context, qtx *database.Queries, chirp_id uuid.UUID, p pollParams) error {
+
+	poll, err := qtx.CreatePoll(ctx, database.CreatePollParams{
+		ChirpID:        chirp_id,
+		ClosesAt:       p.ClosesAt.UTC(),
+		MultipleChoice: p.MultipleChoice,
+		HideResults:    p.HideResults,
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, option := range p.Options {
+		_, err = qtx.CreatePollOption(ctx, database.CreatePollOptionParams{
+			PollID:   poll.ID,
+			Position: int32(i),
+			Text:     option,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// loadChirpPolls builds the poll of each chirp as seen by viewer, keyed by
+// chirp id. Tallies of polls with hidden results are only shown to the
+// author until the poll closes.
+func (cfg *apiConfig) loadChirpPolls(ctx context.Context, chirps []database.Chirp, viewer uuid.UUID) (map[uuid.UUID]*pollInfo, error) {
+
+	polls := map[uuid.UUID]*pollInfo{}
+	if len(chirps) == 0 {
+		return polls, nil
+	}
+
+	authors := map[uuid.UUID]uuid.UUID{}
+	chirp_ids := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		authors[chirp.ID] = chirp.UserID
+		chirp_ids = append(chirp_ids, chirp.ID)
+	}
+
+	rows, err := cfg.db.GetPollsForChirps(ctx, chirp_ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return polls, nil
+	}
+
+	now := time.Now().UTC()
+	by_poll := map[uuid.UUID]*pollInfo{}
+	poll_ids := make([]uuid.UUID, 0, len(rows))
+	for _, row := range rows {
+		closed := !now.Before(row.ClosesAt)
+		hidden := row.HideResults && !closed && authors[row.ChirpID] != viewer
+
+		info := &pollInfo{
+			Id:             row.ID,
+			ClosesAt:       row.ClosesAt,
+			Closed:         closed,
+			MultipleChoice: row.MultipleChoice,
+			ResultsHidden:  hidden,
+			Options:        []pollOptionInfo{},
+			ViewerVotes:    []uuid.UUID{},
+		}
+		if !hidden {
+			voters := row.VoterCount
+			info.VoterCount = &voters
+		}
+
+		polls[row.ChirpID] = info
+		by_poll[row.ID] = info
+		poll_ids = append(poll_ids, row.ID)
+	}
+
+	tallies, err := cfg.db.GetPollTallies(ctx, poll_ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, tally := range tallies {
+		info := by_poll[tally.PollID]
+		option := pollOptionInfo{Id: tally.ID, Text: tally.Text}
+		if !info.ResultsHidden {
+			votes := tally.Votes
+			option.Votes = &votes
+		}
+		info.Options = append(info.Options, option)
+	}
+
+	if viewer != uuid.Nil {
+		votes, err := cfg.db.GetViewerPollVotes(ctx, database.GetViewerPollVotesParams{
+			UserID:  viewer,
+			PollIds: poll_ids,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, vote := range votes {
+			info := by_poll[vote.PollID]
+			info.ViewerVotes = append(info.ViewerVotes, vote.OptionID)
+		}
+	}
+
+	return polls, nil
+}
+
+func (cfg *apiConfig) votePoll(w http.ResponseWriter, r *http.Request) {
+
+	type parameters struct {
+		OptionIDs []uuid.UUID `json:"option_ids"`
+	}
+
+	user_id := principalOf(r).UserID
+
+	if !cfg.checkStanding(w, r, user_id) {
+		return
+	}
+
+	c_id, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't convert id into uuid", err)
+		return
+	}
+
+	params := parameters{}
+	if !cfg.decodeJSON(w, r, &params) {
+		return
+	}
+
+	chirp, err := cfg.db.GetAChirp(r.Context(), database.GetAChirpParams{
+		ID:       c_id,
+		ViewerID: user_id,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "couldn't find id in the server", err)
+		return
+	}
+
+	poll, err := cfg.db.GetPollByChirpID(r.Context(), chirp.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "chirp has no poll", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to get poll Failed", err)
+		return
+	}
+
+	if !time.Now().UTC().Before(poll.ClosesAt) {
+		respondWithError(w, http.StatusConflict, "poll is closed", nil)
+		return
+	}
+
+	if len(params.OptionIDs) == 0 || (!poll.MultipleChoice && len(params.OptionIDs) > 1) {
+		respondWithError(w, http.StatusBadRequest, "pick exactly one option, or at least one for multiple choice polls", nil)
+		return
+	}
+
+	options, err := cfg.db.GetPollTallies(r.Context(), []uuid.UUID{poll.ID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to get poll options Failed", err)
+		return
+	}
+	valid := map[uuid.UUID]bool{}
+	for _, option := range options {
+		valid[option.ID] = true
+	}
+	for _, o_id := range params.OptionIDs {
+		if !valid[o_id] {
+			respondWithError(w, http.StatusBadRequest, "option doesn't belong to this poll", nil)
+			return
+		}
+		// each option may only be picked once
+		valid[o_id] = false
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	created, err := qtx.CreatePollBallot(r.Context(), database.CreatePollBallotParams{
+		PollID: poll.ID,
+		UserID: user_id,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to vote Failed", err)
+		return
+	}
+	if created == 0 {
+		respondWithError(w, http.StatusConflict, "you have already voted in this poll", nil)
+		return
+	}
+
+	for _, o_id := range params.OptionIDs {
+		err = qtx.CreatePollVote(r.Context(), database.CreatePollVoteParams{
+			PollID:   poll.ID,
+			UserID:   user_id,
+			OptionID: o_id,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "db request to vote Failed", err)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't commit transaction", err)
+		return
+	}
+
+	polls, err := cfg.loadChirpPolls(r.Context(), []database.Chirp{chirp}, user_id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to get poll Failed", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, polls[chirp.ID])
+}
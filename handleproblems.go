@@ -0,0 +1,24 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/frozendolphin/Chirpy/internal/problem"
+)
+
+// listProblems serves the catalogue of problem types, so clients can see
+// every code they may need to handle.
+func listProblems(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, problem.Catalogue())
+}
+
+// getProblem describes one problem type. Each problem's type URI points here.
+func getProblem(w http.ResponseWriter, r *http.Request) {
+
+	t, ok := problem.Lookup(problem.Code(r.PathValue("code")))
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "no such problem type", nil)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, t)
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/frozendolphin/Chirpy/internal/database"
+	"github.com/frozendolphin/Chirpy/internal/validate"
+	"github.com/frozendolphin/Chirpy/internal/webhook"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// publicUser is the representation of a user that anyone may see.
+// It must never carry the email or any other private account data.
+type publicUser struct {
+	Id             uuid.UUID `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	Handle         string    `json:"handle"`
+	DisplayName    string    `json:"display_name"`
+	Bio            string    `json:"bio"`
+	AvatarUrl      string    `json:"avatar_url"`
+	IsChirpyRed    bool      `json:"is_chirpy_red"`
+	Badge          string    `json:"badge,omitempty"`
+	FollowerCount  int64     `json:"follower_count"`
+	FollowingCount int64     `json:"following_count"`
+	ChirpCount     int64     `json:"chirp_count"`
+}
+
+const maxAvatarUrlLength = 2048
+
+func validateAvatarUrl(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	if len(raw) > maxAvatarUrlLength {
+		return errors.New("avatar_url is too long")
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("avatar_url must be an http or https url")
+	}
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func (cfg *apiConfig) getUserProfile(w http.ResponseWriter, r *http.Request) {
+
+	handle := r.PathValue("handle")
+	if handle == "" {
+		respondWithError(w, http.StatusBadRequest, "no such wildcard in path", nil)
+		return
+	}
+
+	profile, err := cfg.db.GetUserProfileByHandle(r.Context(), handle)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "couldn't find the user", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to get profile Failed", err)
+		return
+	}
+	if profile.BannedAt.Valid {
+		respondWithError(w, http.StatusNotFound, "couldn't find the user", nil)
+		return
+	}
+
+	res := publicUser{
+		Id:             profile.ID,
+		CreatedAt:      profile.CreatedAt,
+		Handle:         profile.Handle,
+		DisplayName:    profile.DisplayName,
+		Bio:            profile.Bio,
+		AvatarUrl:      profile.AvatarUrl,
+		IsChirpyRed:    profile.PlanID != freePlan,
+		Badge:          profile.Badge,
+		FollowerCount:  profile.FollowerCount,
+		FollowingCount: profile.FollowingCount,
+		ChirpCount:     profile.ChirpCount,
+	}
+
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+func (cfg *apiConfig) updateProfile(w http.ResponseWriter, r *http.Request) {
+
+	// nil fields are left unchanged
+	type parameters struct {
+		Handle      *string `json:"handle"`
+		DisplayName *string `json:"display_name" validate:"max=50"`
+		Bio         *string `json:"bio" validate:"max=160"`
+		AvatarUrl   *string `json:"avatar_url"`
+	}
+
+	user_id := principalOf(r).UserID
+
+	if !cfg.checkStanding(w, r, user_id) {
+		return
+	}
+
+	params := parameters{}
+	if !cfg.decodeJSON(w, r, &params) {
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), user_id)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "couldn't find the user", err)
+		return
+	}
+
+	update := database.UpdateUserProfileParams{
+		Handle:      user.Handle,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		AvatarUrl:   user.AvatarUrl,
+		ID:          user.ID,
+	}
+
+	if params.Handle != nil {
+		err = validate.Handle(*params.Handle)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		update.Handle = *params.Handle
+	}
+	if params.DisplayName != nil {
+		update.DisplayName = *params.DisplayName
+	}
+	if params.Bio != nil {
+		update.Bio = *params.Bio
+	}
+	if params.AvatarUrl != nil {
+		err = validateAvatarUrl(*params.AvatarUrl)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error(), err)
+			return
+		}
+		update.AvatarUrl = *params.AvatarUrl
+	}
+
+	user, err = cfg.db.UpdateUserProfile(r.Context(), update)
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "handle already taken", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "couldn't update the database", err)
+		return
+	}
+
+	is_red, err := cfg.isChirpyRed(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to get plan Failed", err)
+		return
+	}
+
+	res := usersInfo{
+		Id:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+		Email:       user.Email,
+		Handle:      user.Handle,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		AvatarUrl:   user.AvatarUrl,
+		IsChiryRed:  is_red,
+	}
+
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+func (cfg *apiConfig) followUser(w http.ResponseWriter, r *http.Request) {
+
+	follower_id, followee, ok := cfg.resolveFollow(w, r)
+	if !ok {
+		return
+	}
+
+	if follower_id == followee.ID {
+		respondWithError(w, http.StatusBadRequest, "you can't follow yourself", nil)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	followed, err := qtx.FollowUser(r.Context(), database.FollowUserParams{
+		FollowerID: follower_id,
+		FolloweeID: followee.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to follow user Failed", err)
+		return
+	}
+
+	// following someone twice is a no-op, not a second event
+	if followed > 0 {
+		event := followEvent{FollowerId: follower_id, FolloweeId: followee.ID}
+		err = enqueueEvent(r.Context(), qtx, webhook.UserFollowed, event, follower_id, followee.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "db request to queue webhooks Failed", err)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't commit transaction", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) unfollowUser(w http.ResponseWriter, r *http.Request) {
+
+	follower_id, followee, ok := cfg.resolveFollow(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	unfollowed, err := qtx.UnfollowUser(r.Context(), database.UnfollowUserParams{
+		FollowerID: follower_id,
+		FolloweeID: followee.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to unfollow user Failed", err)
+		return
+	}
+
+	if unfollowed > 0 {
+		event := followEvent{FollowerId: follower_id, FolloweeId: followee.ID}
+		err = enqueueEvent(r.Context(), qtx, webhook.UserUnfollowed, event, follower_id, followee.ID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "db request to queue webhooks Failed", err)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't commit transaction", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resolveFollow checks the caller's standing and looks up the user named by the
+// {handle} path value. It writes the error response itself when ok is false.
+func (cfg *apiConfig) resolveFollow(w http.ResponseWriter, r *http.Request) (uuid.UUID, database.User, bool) {
+
+	user_id := principalOf(r).UserID
+
+	if !cfg.checkStanding(w, r, user_id) {
+		return uuid.Nil, database.User{}, false
+	}
+
+	followee, err := cfg.db.GetUserByHandle(r.Context(), r.PathValue("handle"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "couldn't find the user", err)
+		return uuid.Nil, database.User{}, false
+	}
+
+	return user_id, followee, true
+}
@@ -13,30 +13,14 @@ func (cfg *apiConfig) newRefresh(w http.ResponseWriter, r *http.Request) {
 		Token string `json:"token"`
 	}
 
-	rtoken, err := auth.GetBearerRefreshToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find refresh token", err)
-		return
-	}
-
-	rt_info, err := cfg.db.GetRefreshToken(r.Context(), rtoken)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find refresh token", err)
-		return
-	}
+	user_id := principalOf(r).UserID
 
-	if rt_info.ExpiresAt.Before(time.Now()) {
-		respondWithError(w, http.StatusUnauthorized, "Refresh token expired", nil)
-		return
-	}
-
-	if rt_info.RevokedAt.Valid {
-		respondWithError(w, http.StatusUnauthorized, "Refresh token has been revoked", nil)
+	if !cfg.checkStanding(w, r, user_id) {
 		return
 	}
 
 	expiresIn := 1 * time.Hour
-	new_token, err := auth.MakeJWT(rt_info.UserID, cfg.secret, expiresIn)
+	new_token, err := auth.MakeJWT(user_id, cfg.secret, expiresIn)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't create new jwt", err)
 		return
@@ -51,9 +35,11 @@ func (cfg *apiConfig) newRefresh(w http.ResponseWriter, r *http.Request) {
 
 func (cfg *apiConfig) revokeRefresh(w http.ResponseWriter, r *http.Request) {
 
+	// expired tokens can still be revoked, so this route doesn't use
+	// requireRefreshToken
 	rtoken, err := auth.GetBearerRefreshToken(r.Header)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find refresh token", err)
+		respondUnauthorized(w, withAuthFailure(r, err), "refresh token required")
 		return
 	}
 
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/frozendolphin/Chirpy/internal/database"
+	"github.com/frozendolphin/Chirpy/internal/jobs"
+	"github.com/google/uuid"
+)
+
+// chirpStatus works out how a new or edited chirp should be stored.
+// Drafts are never published automatically; a publish_at alone schedules it.
+func chirpStatus(draft bool, publish_at *time.Time) (string, sql.NullTime, error) {
+
+	if publish_at == nil {
+		if draft {
+			return "draft", sql.NullTime{}, nil
+		}
+		return "published", sql.NullTime{}, nil
+	}
+
+	if !publish_at.After(time.Now()) {
+		return "", sql.NullTime{}, errors.New("publish_at must be in the future")
+	}
+
+	at := sql.NullTime{Time: publish_at.UTC(), Valid: true}
+	if draft {
+		return "draft", at, nil
+	}
+	return "scheduled", at, nil
+}
+
+func (cfg *apiConfig) getScheduledChirps(w http.ResponseWriter, r *http.Request) {
+
+	user_id := principalOf(r).UserID
+
+	if !cfg.checkStanding(w, r, user_id) {
+		return
+	}
+
+	chirps, err := cfg.db.GetUnpublishedChirpsForUser(r.Context(), user_id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to get scheduled chirps Failed", err)
+		return
+	}
+
+	extras, err := cfg.loadChirpExtras(r.Context(), chirps, user_id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to get chirp details Failed", err)
+		return
+	}
+
+	chirp_list := []chirpInfo{}
+	for _, chirp := range chirps {
+		chirp_list = append(chirp_list, newChirpInfo(chirp, extras))
+	}
+
+	respondWithJSON(w, http.StatusOK, chirp_list)
+}
+
+func (cfg *apiConfig) updateScheduledChirp(w http.ResponseWriter, r *http.Request) {
+
+	type parameters struct {
+		Body       string     `json:"body"`
+		Visibility string     `json:"visibility"`
+		PublishAt  *time.Time `json:"publish_at"`
+		Draft      bool       `json:"draft"`
+	}
+
+	user_id := principalOf(r).UserID
+
+	if !cfg.checkStanding(w, r, user_id) {
+		return
+	}
+
+	c_id, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't convert id into uuid", err)
+		return
+	}
+
+	params := parameters{}
+	if !cfg.decodeJSON(w, r, &params) {
+		return
+	}
+
+	if !params.Draft && params.PublishAt == nil {
+		respondWithError(w, http.StatusBadRequest, "publish_at is required unless the chirp is a draft", nil)
+		return
+	}
+
+	plan, err := cfg.db.GetUserPlan(r.Context(), user_id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to get plan Failed", err)
+		return
+	}
+
+	cleaned, flags, err := cfg.validateChirp(params.Body, plan)
+	if err != nil {
+		respondWithChirpError(w, err)
+		return
+	}
+
+	status, publish_at, err := chirpStatus(params.Draft, params.PublishAt)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	if publish_at.Valid && !plan.CanSchedule {
+		respondWithError(w, http.StatusForbidden, "your plan doesn't include scheduled chirps", nil)
+		return
+	}
+
+	visibility, err := chirpVisibility(params.Visibility)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	if publish_at.Valid {
+		poll, err := cfg.db.GetPollByChirpID(r.Context(), c_id)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusInternalServerError, "db request to get poll Failed", err)
+			return
+		}
+		if err == nil && !poll.ClosesAt.After(publish_at.Time) {
+			respondWithError(w, http.StatusBadRequest, "the chirp's poll would close before it is published", nil)
+			return
+		}
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	chirp, err := qtx.UpdateUnpublishedChirp(r.Context(), database.UpdateUnpublishedChirpParams{
+		Body:       cleaned,
+		Visibility: visibility,
+		Status:     status,
+		PublishAt:  publish_at,
+		ID:         c_id,
+		UserID:     user_id,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "couldn't find a scheduled chirp with that id", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to update chirp Failed", err)
+		return
+	}
+
+	err = flagChirp(r.Context(), qtx, chirp.ID, flags)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to flag chirp Failed", err)
+		return
+	}
+
+	// any job queued for the old publish_at finds the chirp not yet due and
+	// does nothing
+	if status == "scheduled" {
+		err = enqueueJob(r.Context(), qtx, publishChirpArgs{ChirpID: chirp.ID}, jobs.Options{RunAt: publish_at.Time})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "db request to schedule publishing Failed", err)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't commit transaction", err)
+		return
+	}
+
+	extras, err := cfg.loadChirpExtras(r.Context(), []database.Chirp{chirp}, user_id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to get chirp details Failed", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newChirpInfo(chirp, extras))
+}
+
+func (cfg *apiConfig) cancelScheduledChirp(w http.ResponseWriter, r *http.Request) {
+
+	user_id := principalOf(r).UserID
+
+	if !cfg.checkStanding(w, r, user_id) {
+		return
+	}
+
+	c_id, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't convert id into uuid", err)
+		return
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	media, err := qtx.GetMediaForChirps(r.Context(), []uuid.UUID{c_id})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to get media Failed", err)
+		return
+	}
+
+	deleted, err := qtx.DeleteUnpublishedChirp(r.Context(), database.DeleteUnpublishedChirpParams{
+		ID:     c_id,
+		UserID: user_id,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to delete chirp Failed", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "couldn't find a scheduled chirp with that id", nil)
+		return
+	}
+
+	// the media rows go with the chirp; their files are removed afterwards
+	err = enqueueMediaCleanup(r.Context(), qtx, media)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to queue media cleanup Failed", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't commit transaction", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
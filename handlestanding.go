@@ -0,0 +1,457 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/frozendolphin/Chirpy/internal/auth"
+	"github.com/frozendolphin/Chirpy/internal/database"
+	"github.com/frozendolphin/Chirpy/internal/problem"
+	"github.com/frozendolphin/Chirpy/internal/validate"
+	"github.com/google/uuid"
+)
+
+// accountStanding is what a user's account is allowed to do. Shadow limits
+// are never shown to the user they apply to.
+type accountStanding struct {
+	Status string     `json:"status"`
+	Reason string     `json:"reason,omitempty"`
+	Until  *time.Time `json:"until,omitempty"`
+}
+
+// standingOf works out the effective standing of user. A ban wins over a
+// suspension, and suspensions lapse on their own once suspended_until passes.
+func standingOf(user database.User, now time.Time) accountStanding {
+
+	switch {
+	case user.BannedAt.Valid:
+		return accountStanding{Status: "banned", Reason: user.SuspensionReason}
+	case user.SuspendedUntil.Valid && user.SuspendedUntil.Time.After(now):
+		until := user.SuspendedUntil.Time
+		return accountStanding{Status: "suspended", Reason: user.SuspensionReason, Until: &until}
+	case user.ShadowLimited:
+		return accountStanding{Status: "shadow_limited"}
+	}
+	return accountStanding{Status: "active"}
+}
+
+// restricted reports whether the account may not sign in or act.
+func (s accountStanding) restricted() bool {
+	return s.Status == "banned" || s.Status == "suspended"
+}
+
+func respondRestricted(w http.ResponseWriter, standing accountStanding) {
+
+	p := problem.New(problem.CodeAccountRestricted, 0, "account is "+standing.Status)
+	respondWithProblem(w, p.With("standing", standing), nil)
+}
+
+// checkStanding refuses requests from suspended or banned users whose
+// access token was issued before the restriction. It writes the error
+// response itself when it returns false.
+func (cfg *apiConfig) checkStanding(w http.ResponseWriter, r *http.Request, user_id uuid.UUID) bool {
+
+	// the caller's own account was loaded by authenticate already
+	user, ok := authUserOf(r)
+	if !ok || user.ID != user_id {
+		var err error
+		user, err = cfg.db.GetUserByID(r.Context(), user_id)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, "couldn't find the user", err)
+			return false
+		}
+	}
+
+	standing := standingOf(user, time.Now().UTC())
+	if standing.restricted() {
+		respondRestricted(w, standing)
+		return false
+	}
+	return true
+}
+
+type appealInfo struct {
+	Id         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	UserId     uuid.UUID  `json:"user_id"`
+	Message    string     `json:"message"`
+	Status     string     `json:"status"`
+	Response   string     `json:"response"`
+	ReviewedBy *uuid.UUID `json:"reviewed_by"`
+	ReviewedAt *time.Time `json:"reviewed_at"`
+}
+
+func newAppealInfo(a database.Appeal) appealInfo {
+
+	info := appealInfo{
+		Id:        a.ID,
+		CreatedAt: a.CreatedAt,
+		UpdatedAt: a.UpdatedAt,
+		UserId:    a.UserID,
+		Message:   a.Message,
+		Status:    a.Status,
+		Response:  a.Response,
+	}
+	if a.ReviewedBy.Valid {
+		info.ReviewedBy = &a.ReviewedBy.UUID
+	}
+	if a.ReviewedAt.Valid {
+		info.ReviewedAt = &a.ReviewedAt.Time
+	}
+	return info
+}
+
+// createAppeal lets a suspended or banned user ask for a review. They can't
+// get tokens, so the request carries their credentials instead.
+func (cfg *apiConfig) createAppeal(w http.ResponseWriter, r *http.Request) {
+
+	type parameters struct {
+		Email    string `json:"email" validate:"required"`
+		Password string `json:"password" validate:"required"`
+		Message  string `json:"message" validate:"required,max=1000"`
+	}
+
+	params := parameters{}
+	if !cfg.decodeJSON(w, r, &params) {
+		return
+	}
+	params.Email = validate.NormalizeEmail(params.Email)
+
+	user, err := cfg.db.GetUserByEmail(r.Context(), params.Email)
+	if err != nil {
+		respondWithProblem(w, problem.New(problem.CodeInvalidCredentials, 0, "Incorrect email or password"), err)
+		return
+	}
+
+	err = auth.CheckPasswordHash(r.Context(), params.Password, user.HashedPassword)
+	if err != nil {
+		respondWithProblem(w, problem.New(problem.CodeInvalidCredentials, 0, "Incorrect email or password"), err)
+		return
+	}
+
+	if !standingOf(user, time.Now().UTC()).restricted() {
+		respondWithError(w, http.StatusBadRequest, "only suspended or banned accounts can appeal", nil)
+		return
+	}
+
+	params.Message = strings.TrimSpace(params.Message)
+
+	appeal, err := cfg.db.CreateAppeal(r.Context(), database.CreateAppealParams{
+		UserID:  user.ID,
+		Message: params.Message,
+	})
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "you already have an appeal waiting for review", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to create appeal Failed", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, newAppealInfo(appeal))
+}
+
+func (cfg *apiConfig) listAppeals(w http.ResponseWriter, r *http.Request) {
+
+	type appealQueueItem struct {
+		appealInfo
+		Handle   string          `json:"handle"`
+		Standing accountStanding `json:"standing"`
+	}
+
+	_, ok := cfg.requireRole(w, r, "moderator", "admin")
+	if !ok {
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = "open"
+	}
+	if status != "open" && status != "accepted" && status != "rejected" {
+		respondWithError(w, http.StatusBadRequest, "status must be open, accepted or rejected", nil)
+		return
+	}
+
+	rows, err := cfg.db.ListAppeals(r.Context(), status)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to list appeals Failed", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	res := []appealQueueItem{}
+	for _, row := range rows {
+		res = append(res, appealQueueItem{
+			appealInfo: newAppealInfo(database.Appeal{
+				ID:         row.ID,
+				CreatedAt:  row.CreatedAt,
+				UpdatedAt:  row.UpdatedAt,
+				UserID:     row.UserID,
+				Message:    row.Message,
+				Status:     row.Status,
+				Response:   row.Response,
+				ReviewedBy: row.ReviewedBy,
+				ReviewedAt: row.ReviewedAt,
+			}),
+			Handle: row.Handle,
+			Standing: standingOf(database.User{
+				SuspendedUntil:   row.SuspendedUntil,
+				BannedAt:         row.BannedAt,
+				SuspensionReason: row.SuspensionReason,
+			}, now),
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+func (cfg *apiConfig) reviewAppeal(w http.ResponseWriter, r *http.Request) {
+
+	type parameters struct {
+		Decision string `json:"decision" validate:"required,oneof=accept reject"`
+		Response string `json:"response" validate:"max=1000"`
+	}
+
+	moderator, ok := cfg.requireRole(w, r, "moderator", "admin")
+	if !ok {
+		return
+	}
+
+	appeal_id, err := uuid.Parse(r.PathValue("appealID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't convert id into uuid", err)
+		return
+	}
+
+	params := parameters{}
+	if !cfg.decodeJSON(w, r, &params) {
+		return
+	}
+
+	status := "accepted"
+	if params.Decision == "reject" {
+		status = "rejected"
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	appeal, err := qtx.ReviewAppeal(r.Context(), database.ReviewAppealParams{
+		Status:     status,
+		Response:   params.Response,
+		ReviewedBy: uuid.NullUUID{UUID: moderator.ID, Valid: true},
+		ID:         appeal_id,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusConflict, "appeal doesn't exist or was already reviewed", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to review appeal Failed", err)
+		return
+	}
+
+	// the same rule as setUserStanding: only a higher role may decide on an
+	// account's restrictions, and never the account itself
+	if appeal.UserID == moderator.ID {
+		respondWithError(w, http.StatusForbidden, "you can't review your own appeal", nil)
+		return
+	}
+	appellant, err := qtx.GetUserByID(r.Context(), appeal.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to get user Failed", err)
+		return
+	}
+	if !auth.Outranks(moderator.Role, appellant.Role) {
+		respondWithError(w, http.StatusForbidden, "you can only review appeals from users below your role", nil)
+		return
+	}
+
+	if status == "accepted" {
+		err = qtx.LiftUserRestrictions(r.Context(), appeal.UserID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "db request to lift restrictions Failed", err)
+			return
+		}
+	}
+
+	err = qtx.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+		SubjectUserID: uuid.NullUUID{UUID: appeal.UserID, Valid: true},
+		ModeratorID:   uuid.NullUUID{UUID: moderator.ID, Valid: true},
+		Action:        "appeal_" + status,
+		Note:          params.Response,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to record moderation action Failed", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't commit transaction", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newAppealInfo(appeal))
+}
+
+func (cfg *apiConfig) getUserStanding(w http.ResponseWriter, r *http.Request) {
+
+	type response struct {
+		accountStanding
+		ShadowLimited bool                   `json:"shadow_limited"`
+		History       []moderationActionInfo `json:"history"`
+	}
+
+	_, ok := cfg.requireRole(w, r, "moderator", "admin")
+	if !ok {
+		return
+	}
+
+	user_id, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't convert id into uuid", err)
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), user_id)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "couldn't find the user", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to get user Failed", err)
+		return
+	}
+
+	actions, err := cfg.db.ListAccountActions(r.Context(), uuid.NullUUID{UUID: user.ID, Valid: true})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to get moderation actions Failed", err)
+		return
+	}
+
+	res := response{
+		accountStanding: standingOf(user, time.Now().UTC()),
+		ShadowLimited:   user.ShadowLimited,
+		History:         []moderationActionInfo{},
+	}
+	for _, action := range actions {
+		res.History = append(res.History, newModerationActionInfo(action))
+	}
+
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+// setUserStanding replaces the restrictions on an account. The states are
+// exclusive: setting one clears the others, and "active" clears them all.
+func (cfg *apiConfig) setUserStanding(w http.ResponseWriter, r *http.Request) {
+
+	type parameters struct {
+		Status string     `json:"status" validate:"required,oneof=active suspended banned shadow_limited"`
+		Reason string     `json:"reason" validate:"max=1000"`
+		Until  *time.Time `json:"until"`
+	}
+
+	moderator, ok := cfg.requireRole(w, r, "moderator", "admin")
+	if !ok {
+		return
+	}
+
+	user_id, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't convert id into uuid", err)
+		return
+	}
+
+	params := parameters{}
+	if !cfg.decodeJSON(w, r, &params) {
+		return
+	}
+
+	if user_id == moderator.ID {
+		respondWithError(w, http.StatusBadRequest, "you can't change your own standing", nil)
+		return
+	}
+
+	target, err := cfg.db.GetUserByID(r.Context(), user_id)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "couldn't find the user", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to get user Failed", err)
+		return
+	}
+	if !auth.Outranks(moderator.Role, target.Role) {
+		respondWithError(w, http.StatusForbidden, "you can only change the standing of users below your role", nil)
+		return
+	}
+
+	now := time.Now().UTC()
+	update := database.SetUserStandingParams{
+		SuspensionReason: params.Reason,
+		ID:               user_id,
+	}
+	switch params.Status {
+	case "active":
+		update.SuspensionReason = ""
+	case "suspended":
+		if params.Until == nil || !params.Until.After(now) {
+			respondWithError(w, http.StatusBadRequest, "until must be a time in the future", nil)
+			return
+		}
+		update.SuspendedUntil = sql.NullTime{Time: params.Until.UTC(), Valid: true}
+	case "banned":
+		update.BannedAt = sql.NullTime{Time: now, Valid: true}
+	case "shadow_limited":
+		update.ShadowLimited = true
+	}
+
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start transaction", err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	user, err := qtx.SetUserStanding(r.Context(), update)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "couldn't find the user", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to set user standing Failed", err)
+		return
+	}
+
+	err = qtx.CreateModerationAction(r.Context(), database.CreateModerationActionParams{
+		SubjectUserID: uuid.NullUUID{UUID: user.ID, Valid: true},
+		ModeratorID:   uuid.NullUUID{UUID: moderator.ID, Valid: true},
+		Action:        "set_" + params.Status,
+		Note:          params.Reason,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to record moderation action Failed", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't commit transaction", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, standingOf(user, now))
+}
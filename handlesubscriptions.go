@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	freePlan      = "free"
+	chirpyRedPlan = "red"
+
+	// subscriptionPeriod is how long a paid period lasts when Polka doesn't say
+	subscriptionPeriod = 30 * 24 * time.Hour
+)
+
+// isChirpyRed reports whether the user is currently on a paid plan.
+func (cfg *apiConfig) isChirpyRed(ctx context.Context, user_id uuid.UUID) (bool, error) {
+
+	plan, err := cfg.db.GetUserPlan(ctx, user_id)
+	if err != nil {
+		return false, err
+	}
+	return plan.ID != freePlan, nil
+}
+
+func (cfg *apiConfig) getMySubscription(w http.ResponseWriter, r *http.Request) {
+
+	type response struct {
+		Plan               planInfo   `json:"plan"`
+		Status             string     `json:"status"`
+		CurrentPeriodStart *time.Time `json:"current_period_start"`
+		CurrentPeriodEnd   *time.Time `json:"current_period_end"`
+		CanceledAt         *time.Time `json:"canceled_at"`
+	}
+
+	user_id := principalOf(r).UserID
+
+	if !cfg.checkStanding(w, r, user_id) {
+		return
+	}
+
+	plan, err := cfg.db.GetUserPlan(r.Context(), user_id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to get plan Failed", err)
+		return
+	}
+
+	res := response{
+		Plan: planInfo{
+			Id:                plan.ID,
+			Name:              plan.Name,
+			MaxChirpLength:    plan.MaxChirpLength,
+			EditWindowSeconds: plan.EditWindowSeconds,
+			ChirpsPerHour:     plan.ChirpsPerHour,
+			MaxMediaPerChirp:  plan.MaxMediaPerChirp,
+			CanSchedule:       plan.CanSchedule,
+			Badge:             plan.Badge,
+		},
+		Status: "none",
+	}
+
+	sub, err := cfg.db.GetSubscription(r.Context(), user_id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusInternalServerError, "db request to get subscription Failed", err)
+		return
+	}
+	if err == nil {
+		res.Status = sub.Status
+		if !sub.CurrentPeriodEnd.After(time.Now().UTC()) {
+			res.Status = "expired"
+		}
+		res.CurrentPeriodStart = &sub.CurrentPeriodStart
+		res.CurrentPeriodEnd = &sub.CurrentPeriodEnd
+		if sub.CanceledAt.Valid {
+			res.CanceledAt = &sub.CanceledAt.Time
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, res)
+}
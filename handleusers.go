@@ -1,12 +1,16 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/frozendolphin/Chirpy/internal/auth"
 	"github.com/frozendolphin/Chirpy/internal/database"
+	"github.com/frozendolphin/Chirpy/internal/config"
+	"github.com/frozendolphin/Chirpy/internal/password"
+	"github.com/frozendolphin/Chirpy/internal/problem"
+	"github.com/frozendolphin/Chirpy/internal/validate"
 	"github.com/google/uuid"
 )
 
@@ -15,6 +19,10 @@ type usersInfo struct {
 		CreatedAt time.Time `json:"created_at"`
 		UpdatedAt time.Time `json:"updated_at"`
 		Email string `json:"email"`
+		Handle string `json:"handle"`
+		DisplayName string `json:"display_name"`
+		Bio string `json:"bio"`
+		AvatarUrl string `json:"avatar_url"`
 		Token string `json:"token"`
 		RefreshToken string `json:"refresh_token"`
 		IsChiryRed bool `json:"is_chirpy_red"`
@@ -23,20 +31,29 @@ type usersInfo struct {
 func (cfg *apiConfig) createUsers(w http.ResponseWriter, r *http.Request) {
 	
 	type mail struct {
-		Email string `json:"email"`
-		Password string `json:"password"`
+		Email string `json:"email" validate:"required,email"`
+		Password string `json:"password" validate:"required"`
+		Handle string `json:"handle" validate:"required"`
 	}
 
 	params := mail{}
 
-	decoder := json.NewDecoder(r.Body)
-	err := decoder.Decode(&params)
+	if !cfg.decodeJSON(w, r, &params) {
+		return
+	}
+	params.Email = validate.NormalizeEmail(params.Email)
+
+	err := validate.Handle(params.Handle)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
 		return
 	}
 
-	hashedp, err := auth.HashPassword(params.Password)
+	if !cfg.checkPassword(w, params.Password, params.Email, params.Handle) {
+		return
+	}
+
+	hashedp, err := auth.HashPassword(r.Context(), params.Password)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't hash the password", err)
 		return
@@ -45,9 +62,14 @@ func (cfg *apiConfig) createUsers(w http.ResponseWriter, r *http.Request) {
 	createUser_params := database.CreateUserParams {
 		Email: params.Email,
 		HashedPassword: hashedp,
+		Handle: params.Handle,
 	}
 
 	user, err := cfg.db.CreateUser(r.Context(), createUser_params)
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "Email or handle already taken", err)
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't create user", err)
 		return
@@ -58,7 +80,10 @@ func (cfg *apiConfig) createUsers(w http.ResponseWriter, r *http.Request) {
 		CreatedAt: user.CreatedAt,
 		UpdatedAt: user.UpdatedAt,
 		Email: user.Email,
-		IsChiryRed: user.IsChirpyRed,
+		Handle: user.Handle,
+		DisplayName: user.DisplayName,
+		Bio: user.Bio,
+		AvatarUrl: user.AvatarUrl,
 	}
 
 	respondWithJSON(w, http.StatusCreated, res)
@@ -83,36 +108,28 @@ func (cfg *apiConfig) resetHits(w http.ResponseWriter, req *http.Request) {
 func (cfg *apiConfig) changeEmailPass(w http.ResponseWriter, r *http.Request) {
 
 	type requirementreq struct {
-		Email string `json:"email"`
-		Password string `json:"password"`
+		Email string `json:"email" validate:"required,email"`
+		Password string `json:"password" validate:"required"`
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find access token", err)
+	user_id := principalOf(r).UserID
+
+	if !cfg.checkStanding(w, r, user_id) {
 		return
 	}
 
 	requirement := requirementreq{}
-	decoder := json.NewDecoder(r.Body)
-	err = decoder.Decode(&requirement)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode the request data", err)
+	if !cfg.decodeJSON(w, r, &requirement) {
 		return
 	}
 
-	if requirement.Email == "" || requirement.Password == "" {
-		respondWithError(w, http.StatusBadRequest, "Email or Password not found", err)
-		return
-	}
+	requirement.Email = validate.NormalizeEmail(requirement.Email)
 
-	user_id, err := auth.ValidateJWT(token, cfg.secret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "jwt validation failed", err)
+	if !cfg.checkPassword(w, requirement.Password, requirement.Email) {
 		return
 	}
 
-	hashpass, err := auth.HashPassword(requirement.Password)
+	hashpass, err := auth.HashPassword(r.Context(), requirement.Password)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "couldn't hash the password", err)
 		return
@@ -130,13 +147,59 @@ func (cfg *apiConfig) changeEmailPass(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	is_red, err := cfg.isChirpyRed(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to get plan Failed", err)
+		return
+	}
+
 	res := usersInfo {
 		Id: user.ID,
 		CreatedAt: user.CreatedAt,
 		UpdatedAt: user.UpdatedAt,
 		Email: user.Email,
-		IsChiryRed: user.IsChirpyRed,
+		Handle: user.Handle,
+		DisplayName: user.DisplayName,
+		Bio: user.Bio,
+		AvatarUrl: user.AvatarUrl,
+		IsChiryRed: is_red,
 	}	
 
 	respondWithJSON(w, http.StatusOK, res)
-}
\ No newline at end of file
+}
+
+// newPasswordPolicy builds the rules new passwords must follow from conf.
+func newPasswordPolicy(conf config.Config) (password.Policy, error) {
+
+	policy := password.Policy{MinLength: conf.PasswordMinLength}
+	if !conf.PasswordCheckBreached {
+		return policy, nil
+	}
+
+	policy.Breached = password.CommonList()
+	if conf.PasswordBreachedList != "" {
+		err := policy.Breached.Load(conf.PasswordBreachedList)
+		if err != nil {
+			return password.Policy{}, err
+		}
+	}
+	return policy, nil
+}
+
+// checkPassword refuses new passwords that break the password policy.
+// account is what the password mustn't repeat. It writes the problem
+// response itself when it returns false.
+func (cfg *apiConfig) checkPassword(w http.ResponseWriter, pw string, account ...string) bool {
+
+	err := cfg.passwordPolicy.Check(pw, account...)
+	var pw_err *password.Error
+	if errors.As(err, &pw_err) {
+		respondWithProblem(w, problem.Validation(problem.FieldError{
+			Field:   "password",
+			Code:    pw_err.Code,
+			Message: pw_err.Message,
+		}), nil)
+		return false
+	}
+	return true
+}
@@ -1,57 +1,199 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"time"
 
-	"github.com/frozendolphin/Chirpy/internal/auth"
+	"github.com/frozendolphin/Chirpy/internal/database"
+	"github.com/frozendolphin/Chirpy/internal/problem"
 	"github.com/google/uuid"
 )
 
-func (cfg *apiConfig) upgradeUserChirpyRed(w http.ResponseWriter, r *http.Request) {
-	
-	type event struct {
-		Event string `json:"event"`
-		Data struct {
-			User_id string `json:"user_id"`
-		} `json:"data"`
+const maxWebhookBodyBytes = 64 << 10
+
+// outcomes recorded in webhook_events
+const (
+	webhookApplied        = "applied"
+	webhookIgnored        = "ignored"
+	webhookInvalid        = "invalid"
+	webhookUserNotFound   = "user_not_found"
+	webhookNoSubscription = "no_subscription"
+)
+
+type polkaEvent struct {
+	// Id is unique per event and stays the same when Polka retries it
+	Id    string `json:"id"`
+	Event string `json:"event"`
+	Data  struct {
+		User_id string `json:"user_id"`
+		// PeriodEnd is optional; without it a paid period lasts subscriptionPeriod
+		PeriodEnd *time.Time `json:"period_end"`
+	} `json:"data"`
+}
+
+func (cfg *apiConfig) polkaWebhook(w http.ResponseWriter, r *http.Request) {
+
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodyBytes))
+	var max_bytes_err *http.MaxBytesError
+	if errors.As(err, &max_bytes_err) {
+		respondWithProblem(w, decodeProblem(err), err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't read request body", err)
+		return
 	}
 
-	param := event{}
+	// the signature covers the raw bytes, so check it before decoding
+	err = cfg.polkaVerifier.Verify(r.Header, payload)
+	if err != nil {
+		cfg.metrics.polkaWebhooks.WithLabelValues("bad_signature").Inc()
+		respondWithProblem(w, problem.New(problem.CodeInvalidSignature, 0, "webhook signature didn't verify"), err)
+		return
+	}
 
-	decoder := json.NewDecoder(r.Body)
-	err := decoder.Decode(&param)
+	param := polkaEvent{}
+	err = json.Unmarshal(payload, &param)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "couldn't decode response", err)
+		respondWithProblem(w, decodeProblem(err), err)
+		return
+	}
+
+	if param.Id == "" {
+		respondWithError(w, http.StatusBadRequest, "event id is required", nil)
+		return
 	}
 
-	apikey, err := auth.GetAPIKey(r.Header)
+	tx, err := cfg.dbConn.BeginTx(r.Context(), nil)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "no apikey in header", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't start transaction", err)
 		return
 	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
 
-	if cfg.polka_key != apikey {
-		respondWithError(w, http.StatusUnauthorized, "apikey didn't match", err)
+	var user_id uuid.NullUUID
+	outcome := webhookInvalid
+	u_id, parse_err := uuid.Parse(param.Data.User_id)
+	if parse_err == nil {
+		user_id = uuid.NullUUID{UUID: u_id, Valid: true}
+		outcome, err = applyPolkaEvent(r.Context(), qtx, param, u_id)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "couldn't apply the event", err)
+			return
+		}
+		if outcome == webhookUserNotFound {
+			user_id = uuid.NullUUID{}
+		}
+	}
+
+	recorded, err := qtx.CreateWebhookEvent(r.Context(), database.CreateWebhookEventParams{
+		Provider:  "polka",
+		EventID:   sql.NullString{String: param.Id, Valid: true},
+		EventType: param.Event,
+		UserID:    user_id,
+		Payload:   payload,
+		Outcome:   outcome,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to record webhook event Failed", err)
 		return
 	}
 
-	if param.Event != "user.upgraded" {
+	// a retry of an event we already processed: undo this attempt and
+	// acknowledge it so Polka stops sending it
+	if recorded == 0 {
+		cfg.metrics.polkaWebhooks.WithLabelValues("duplicate").Inc()
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
-	u_id, err := uuid.Parse(param.Data.User_id)
+	err = tx.Commit()
 	if err != nil {
-		respondWithError(w, http.StatusBadRequest, "couldn't convert id into uuid", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't commit transaction", err)
 		return
 	}
 
-	err = cfg.db.UpgradeUserChirpyRed(r.Context(), u_id)
+	cfg.metrics.polkaWebhooks.WithLabelValues(outcome).Inc()
+	switch outcome {
+	case webhookInvalid:
+		respondWithError(w, http.StatusBadRequest, "couldn't convert id into uuid", parse_err)
+	case webhookUserNotFound:
+		respondWithError(w, http.StatusNotFound, "couldn't find the user", nil)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// applyPolkaEvent moves the user's subscription through its lifecycle and
+// returns the outcome to record. Unknown events are ignored so Polka can add
+// new ones without breaking us.
+func applyPolkaEvent(ctx context.Context, qtx *database.Queries, param polkaEvent, user_id uuid.UUID) (string, error) {
+
+	_, err := qtx.GetUserByID(ctx, user_id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return webhookUserNotFound, nil
+	}
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "couldn't find the user", err)
-		return
+		return "", err
+	}
+
+	sub, err := qtx.GetSubscription(ctx, user_id)
+	has_sub := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
 	}
-	
-	w.WriteHeader(http.StatusNoContent)
-}
\ No newline at end of file
+
+	now := time.Now().UTC()
+	switch param.Event {
+	case "user.upgraded", "user.renewed":
+		// a renewal extends the running period instead of restarting it
+		start := now
+		if param.Event == "user.renewed" && has_sub && sub.Status != "refunded" && sub.CurrentPeriodEnd.After(now) {
+			start = sub.CurrentPeriodEnd
+		}
+		end := start.Add(subscriptionPeriod)
+		if param.Data.PeriodEnd != nil {
+			end = param.Data.PeriodEnd.UTC()
+		}
+		_, err = qtx.UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+			UserID:             user_id,
+			PlanID:             chirpyRedPlan,
+			CurrentPeriodStart: start,
+			CurrentPeriodEnd:   end,
+		})
+		return webhookApplied, err
+
+	case "user.payment_failed", "user.downgraded", "user.refunded":
+		if !has_sub {
+			return webhookNoSubscription, nil
+		}
+		if param.Event == "user.payment_failed" && sub.Status != "active" {
+			return webhookIgnored, nil
+		}
+		update := database.SetSubscriptionStatusParams{
+			Status:           "past_due",
+			CurrentPeriodEnd: sub.CurrentPeriodEnd,
+			CanceledAt:       sub.CanceledAt,
+			UserID:           user_id,
+		}
+		switch param.Event {
+		case "user.downgraded":
+			update.Status = "canceled"
+			update.CanceledAt = sql.NullTime{Time: now, Valid: true}
+		case "user.refunded":
+			update.Status = "refunded"
+			update.CurrentPeriodEnd = now
+			update.CanceledAt = sql.NullTime{Time: now, Valid: true}
+		}
+		_, err = qtx.SetSubscriptionStatus(ctx, update)
+		return webhookApplied, err
+	}
+
+	return webhookIgnored, nil
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/frozendolphin/Chirpy/internal/database"
+	"github.com/frozendolphin/Chirpy/internal/webhook"
+	"github.com/google/uuid"
+)
+
+const (
+	minWebhookSecretLength = 16
+	webhookDeliveryPage    = 50
+)
+
+type webhookEndpointInfo struct {
+	Id         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	Url        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	AllUsers   bool      `json:"all_users"`
+	// Secret is only returned when the endpoint is created.
+	Secret string `json:"secret,omitempty"`
+}
+
+func newWebhookEndpointInfo(e database.WebhookEndpoint) webhookEndpointInfo {
+	return webhookEndpointInfo{
+		Id:         e.ID,
+		CreatedAt:  e.CreatedAt,
+		Url:        e.Url,
+		EventTypes: e.EventTypes,
+		AllUsers:   e.AllUsers,
+	}
+}
+
+type webhookAttemptInfo struct {
+	AttemptedAt time.Time `json:"attempted_at"`
+	StatusCode  *int32    `json:"status_code"`
+	Error       string    `json:"error,omitempty"`
+	DurationMs  int32     `json:"duration_ms"`
+}
+
+type webhookDeliveryInfo struct {
+	Id            uuid.UUID            `json:"id"`
+	CreatedAt     time.Time            `json:"created_at"`
+	EventId       uuid.UUID            `json:"event_id"`
+	EventType     string               `json:"event_type"`
+	Payload       json.RawMessage      `json:"payload"`
+	Status        string               `json:"status"`
+	Attempts      int32                `json:"attempts"`
+	NextAttemptAt *time.Time           `json:"next_attempt_at"`
+	DeliveredAt   *time.Time           `json:"delivered_at"`
+	Log           []webhookAttemptInfo `json:"log"`
+}
+
+func newWebhookDeliveryInfo(d database.WebhookDelivery, attempts []database.WebhookDeliveryAttempt) webhookDeliveryInfo {
+
+	res := webhookDeliveryInfo{
+		Id:        d.ID,
+		CreatedAt: d.CreatedAt,
+		EventId:   d.EventID,
+		EventType: d.EventType,
+		Payload:   d.Payload,
+		Status:    d.Status,
+		Attempts:  d.Attempts,
+		Log:       []webhookAttemptInfo{},
+	}
+	if d.Status == "pending" {
+		res.NextAttemptAt = &d.NextAttemptAt
+	}
+	if d.DeliveredAt.Valid {
+		res.DeliveredAt = &d.DeliveredAt.Time
+	}
+
+	for _, a := range attempts {
+		if a.DeliveryID != d.ID {
+			continue
+		}
+		info := webhookAttemptInfo{
+			AttemptedAt: a.AttemptedAt,
+			Error:       a.Error,
+			DurationMs:  a.DurationMs,
+		}
+		if a.StatusCode.Valid {
+			info.StatusCode = &a.StatusCode.Int32
+		}
+		res.Log = append(res.Log, info)
+	}
+	return res
+}
+
+func newWebhookSecret() string {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return "whsec_" + hex.EncodeToString(key)
+}
+
+func (cfg *apiConfig) createWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+
+	type parameters struct {
+		Url        string   `json:"url" validate:"required"`
+		EventTypes []string `json:"event_types" validate:"required"`
+		Secret     string   `json:"secret"`
+		AllUsers   bool     `json:"all_users"`
+	}
+
+	user, ok := cfg.requireRole(w, r, "user", "moderator", "admin")
+	if !ok {
+		return
+	}
+
+	params := parameters{}
+	if !cfg.decodeJSON(w, r, &params) {
+		return
+	}
+
+	is_admin := user.Role == "admin"
+	params.Url = strings.TrimSpace(params.Url)
+
+	// internal tools run on the private network, so admins may use plain
+	// http and private addresses; anyone else could use them to probe it
+	err := webhook.ValidateURL(r.Context(), params.Url, is_admin)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+
+	event_types := []string{}
+	for _, t := range params.EventTypes {
+		if !webhook.ValidEventType(t) {
+			msg := fmt.Sprintf("unknown event type %q, expected one of %s", t, strings.Join(webhook.EventTypes, ", "))
+			respondWithError(w, http.StatusBadRequest, msg, nil)
+			return
+		}
+		if !slices.Contains(event_types, t) {
+			event_types = append(event_types, t)
+		}
+	}
+
+	if params.AllUsers && !is_admin {
+		respondWithError(w, http.StatusForbidden, "only admins can receive events for all users", nil)
+		return
+	}
+
+	secret := params.Secret
+	if secret == "" {
+		secret = newWebhookSecret()
+	}
+	if len(secret) < minWebhookSecretLength {
+		msg := fmt.Sprintf("secret must be at least %d characters", minWebhookSecretLength)
+		respondWithError(w, http.StatusBadRequest, msg, nil)
+		return
+	}
+
+	endpoint, err := cfg.db.CreateWebhookEndpoint(r.Context(), database.CreateWebhookEndpointParams{
+		OwnerID:    user.ID,
+		Url:        params.Url,
+		EventTypes: event_types,
+		Secret:     secret,
+		AllUsers:   params.AllUsers,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to create webhook Failed", err)
+		return
+	}
+
+	res := newWebhookEndpointInfo(endpoint)
+	res.Secret = endpoint.Secret
+
+	respondWithJSON(w, http.StatusCreated, res)
+}
+
+func (cfg *apiConfig) listWebhookEndpoints(w http.ResponseWriter, r *http.Request) {
+
+	user, ok := cfg.requireRole(w, r, "user", "moderator", "admin")
+	if !ok {
+		return
+	}
+
+	endpoints, err := cfg.db.ListWebhookEndpoints(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to list webhooks Failed", err)
+		return
+	}
+
+	res := []webhookEndpointInfo{}
+	for _, endpoint := range endpoints {
+		res = append(res, newWebhookEndpointInfo(endpoint))
+	}
+
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+func (cfg *apiConfig) deleteWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+
+	user, ok := cfg.requireRole(w, r, "user", "moderator", "admin")
+	if !ok {
+		return
+	}
+
+	e_id, err := uuid.Parse(r.PathValue("webhookID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't convert id into uuid", err)
+		return
+	}
+
+	deleted, err := cfg.db.DeleteWebhookEndpoint(r.Context(), database.DeleteWebhookEndpointParams{
+		ID:      e_id,
+		OwnerID: user.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to delete webhook Failed", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "couldn't find the webhook", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ownedWebhookEndpoint loads the {webhookID} endpoint if it belongs to the
+// caller. It writes the error response itself when ok is false.
+func (cfg *apiConfig) ownedWebhookEndpoint(w http.ResponseWriter, r *http.Request) (database.WebhookEndpoint, bool) {
+
+	user, ok := cfg.requireRole(w, r, "user", "moderator", "admin")
+	if !ok {
+		return database.WebhookEndpoint{}, false
+	}
+
+	e_id, err := uuid.Parse(r.PathValue("webhookID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't convert id into uuid", err)
+		return database.WebhookEndpoint{}, false
+	}
+
+	endpoint, err := cfg.db.GetWebhookEndpoint(r.Context(), database.GetWebhookEndpointParams{
+		ID:      e_id,
+		OwnerID: user.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "couldn't find the webhook", err)
+		return database.WebhookEndpoint{}, false
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to get webhook Failed", err)
+		return database.WebhookEndpoint{}, false
+	}
+
+	return endpoint, true
+}
+
+func (cfg *apiConfig) listWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+
+	endpoint, ok := cfg.ownedWebhookEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	deliveries, err := cfg.db.ListWebhookDeliveries(r.Context(), database.ListWebhookDeliveriesParams{
+		EndpointID: endpoint.ID,
+		Limit:      webhookDeliveryPage,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to list deliveries Failed", err)
+		return
+	}
+
+	ids := []uuid.UUID{}
+	for _, d := range deliveries {
+		ids = append(ids, d.ID)
+	}
+
+	attempts, err := cfg.db.ListWebhookAttempts(r.Context(), ids)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to list delivery attempts Failed", err)
+		return
+	}
+
+	res := []webhookDeliveryInfo{}
+	for _, d := range deliveries {
+		res = append(res, newWebhookDeliveryInfo(d, attempts))
+	}
+
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+func (cfg *apiConfig) redeliverWebhook(w http.ResponseWriter, r *http.Request) {
+
+	endpoint, ok := cfg.ownedWebhookEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	d_id, err := uuid.Parse(r.PathValue("deliveryID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "couldn't convert id into uuid", err)
+		return
+	}
+
+	delivery, err := cfg.db.RedeliverWebhook(r.Context(), database.RedeliverWebhookParams{
+		ID:         d_id,
+		EndpointID: endpoint.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "couldn't find the delivery", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to redeliver Failed", err)
+		return
+	}
+
+	attempts, err := cfg.db.ListWebhookAttempts(r.Context(), []uuid.UUID{delivery.ID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "db request to list delivery attempts Failed", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, newWebhookDeliveryInfo(delivery, attempts))
+}
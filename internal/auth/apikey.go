@@ -14,6 +14,9 @@ func GetAPIKey(headers http.Header) (string, error) {
 	}
 
 	values := strings.Fields(unclean_apikey)
+	if len(values) != 2 {
+		return "", errors.New("malformed Authorization header")
+	}
 
 	if values[0] != "ApiKey" {
 		return "", errors.New("no ApiKey format")
@@ -1,19 +1,36 @@
 package auth
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"context"
 
-func HashPassword(password string) (string, error) {
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcrypt is slow on purpose, so it gets its own spans
+var tracer = otel.Tracer("github.com/frozendolphin/Chirpy/internal/auth")
+
+func HashPassword(ctx context.Context, password string) (string, error) {
 	
+	_, span := tracer.Start(ctx, "auth.HashPassword")
+	defer span.End()
+
 	hashedp, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
+		span.SetStatus(codes.Error, err.Error())
 		return "", err
 	}
 
 	return string(hashedp), nil
 }
 
-func CheckPasswordHash(password, hash string) error {
+func CheckPasswordHash(ctx context.Context, password, hash string) error {
 
+	_, span := tracer.Start(ctx, "auth.CheckPasswordHash")
+	defer span.End()
+
+	// a wrong password is an expected outcome, not an error in the span
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	if err != nil {
 		return err
@@ -21,4 +38,3 @@ func CheckPasswordHash(password, hash string) error {
 
 	return nil
 }
-
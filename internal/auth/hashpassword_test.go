@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"testing"
 
 	"golang.org/x/crypto/bcrypt"
@@ -10,12 +11,12 @@ func TestPasswordMatch(t *testing.T) {
 
 	password := "yelllows"
 
-	hashedp, err := HashPassword(password)
+	hashedp, err := HashPassword(context.Background(), password)
 	if err != nil {
 		t.Errorf("error happened in hashpassword: %v", err)
 	}
 
-	err = CheckPasswordHash(password, hashedp)
+	err = CheckPasswordHash(context.Background(), password, hashedp)
 	if err != nil {
 		t.Errorf("error happened in checkpasswordhash: %v", err)
 	}
@@ -70,7 +71,7 @@ func TestHashPasswordAndCheck(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Test HashPassword
-			hashedp, err := HashPassword(tt.password)
+			hashedp, err := HashPassword(context.Background(), tt.password)
 			if (err != nil) != tt.wantHashErr {
 				t.Errorf("HashPassword(%q) error = %v, wantErr %v", tt.password, err, tt.wantHashErr)
 				return
@@ -87,7 +88,7 @@ func TestHashPasswordAndCheck(t *testing.T) {
 			}
 
 			// Test CheckPasswordHash
-			err = CheckPasswordHash(tt.checkPass, hashedp)
+			err = CheckPasswordHash(context.Background(), tt.checkPass, hashedp)
 			if (err != nil) != tt.wantCheckErr {
 				t.Errorf("CheckPasswordHash(%q, %q) error = %v, wantErr %v", tt.checkPass, hashedp, err, tt.wantCheckErr)
 			}
@@ -99,7 +100,7 @@ func TestHashPasswordAndCheck(t *testing.T) {
 func TestInvalidHash(t *testing.T) {
 	password := "yelllows"
 	invalidHash := "invalid_hash_format"
-	err := CheckPasswordHash(password, invalidHash)
+	err := CheckPasswordHash(context.Background(), password, invalidHash)
 	if err == nil {
 		t.Errorf("CheckPasswordHash(%q, %q) expected error, got nil", password, invalidHash)
 	}
@@ -111,13 +112,13 @@ func TestInvalidHash(t *testing.T) {
 // TestHashConsistency tests that HashPassword produces consistent results for the same input.
 func TestHashConsistency(t *testing.T) {
 	password := "consistentPass123"
-	hash1, err := HashPassword(password)
+	hash1, err := HashPassword(context.Background(), password)
 	if err != nil {
 		t.Errorf("HashPassword(%q) error = %v", password, err)
 		return
 	}
 	// Verify the hash can be checked
-	err = CheckPasswordHash(password, hash1)
+	err = CheckPasswordHash(context.Background(), password, hash1)
 	if err != nil {
 		t.Errorf("CheckPasswordHash(%q, %q) error = %v", password, hash1, err)
 	}
@@ -129,8 +130,8 @@ func TestCheckPasswordHash(t *testing.T) {
 	// First, we need to create some hashed passwords for testing
 	password1 := "correctPassword123!"
 	password2 := "anotherPassword456!"
-	hash1, _ := HashPassword(password1)
-	hash2, _ := HashPassword(password2)
+	hash1, _ := HashPassword(context.Background(), password1)
+	hash2, _ := HashPassword(context.Background(), password2)
 
 	tests := []struct {
 		name     string
@@ -172,7 +173,7 @@ func TestCheckPasswordHash(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := CheckPasswordHash(tt.password, tt.hash)
+			err := CheckPasswordHash(context.Background(), tt.password, tt.hash)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("CheckPasswordHash() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAuthorizationHeaders(t *testing.T) {
+
+	getters := map[string]struct {
+		get    func(http.Header) (string, error)
+		scheme string
+	}{
+		"GetBearerToken":        {GetBearerToken, "Bearer"},
+		"GetBearerRefreshToken": {GetBearerRefreshToken, "Bearer"},
+		"GetAPIKey":             {GetAPIKey, "ApiKey"},
+	}
+
+	for name, g := range getters {
+		t.Run(name, func(t *testing.T) {
+
+			valid := []string{g.scheme + " tok", g.scheme + "   tok  "}
+			for _, value := range valid {
+				got, err := g.get(http.Header{"Authorization": {value}})
+				if err != nil || got != "tok" {
+					t.Errorf("%s(%q) = %q, %v, want %q", name, value, got, err, "tok")
+				}
+			}
+
+			malformed := []string{
+				"",
+				"   ",
+				g.scheme,
+				g.scheme + " ",
+				"tok",
+				g.scheme + " tok extra",
+				"Basic dXNlcjpwYXNz",
+			}
+			for _, value := range malformed {
+				got, err := g.get(http.Header{"Authorization": {value}})
+				if err == nil {
+					t.Errorf("%s(%q) = %q, want an error", name, value, got)
+				}
+			}
+		})
+	}
+}
@@ -31,45 +31,68 @@ func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (str
 	return token_str, nil
 }
 
+// ErrNoToken means the request carried no Authorization header at all.
+var ErrNoToken = errors.New("token doesn't exist")
+
+// ErrTokenExpired matches tokens rejected only because they have expired.
+var ErrTokenExpired = jwt.ErrTokenExpired
+
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
 
+	p, err := ParseAccessToken(tokenString, tokenSecret)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return p.UserID, nil
+}
+
+// ParseAccessToken validates an access token and returns the principal it
+// was issued to. Roles aren't part of the token; callers fill them in.
+func ParseAccessToken(tokenString, tokenSecret string) (Principal, error) {
+
 	claimsStruct := jwt.RegisteredClaims{}
 	token, err := jwt.ParseWithClaims(tokenString, &claimsStruct, func(t *jwt.Token) (any, error) {
 		return []byte(tokenSecret), nil
-	})
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
 	if err != nil {
-		return uuid.UUID{}, err
+		return Principal{}, err
 	}
 
 	userid, err := token.Claims.GetSubject()
 	if err != nil {
-		return uuid.UUID{}, err
+		return Principal{}, err
 	}
 
 	issuer, err := token.Claims.GetIssuer()
 	if err != nil {
-		return uuid.Nil, err
+		return Principal{}, err
 	}
 	if issuer != "chirpy" {
-		return uuid.Nil, errors.New("invalid issuer")
+		return Principal{}, errors.New("invalid issuer")
 	}
 	
 	id, err := uuid.Parse(userid)
 	if err != nil {
-		return uuid.UUID{}, err
+		return Principal{}, err
 	}
 
-	return id, nil
+	return Principal{
+		UserID:    id,
+		TokenType: TokenAccess,
+	}, nil
 }
 
 func GetBearerToken(headers http.Header) (string, error) {
 	
 	unclean_token := headers.Get("Authorization")
 	if unclean_token == "" {
-		return "", errors.New("token doesn't exist")
+		return "", ErrNoToken
 	}
 
 	values := strings.Fields(unclean_token)
+	if len(values) != 2 {
+		return "", errors.New("malformed Authorization header")
+	}
 
 	if values[0] != "Bearer" {
 		return "", errors.New("no Bearer format")
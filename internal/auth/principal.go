@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"context"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// token types a Principal can be authenticated with
+const (
+	TokenAccess  = "access"
+	TokenRefresh = "refresh"
+)
+
+// Principal is who a request was authenticated as.
+type Principal struct {
+	UserID    uuid.UUID
+	Roles     []string
+	TokenType string
+}
+
+// HasRole reports whether p holds any of roles.
+func (p Principal) HasRole(roles ...string) bool {
+	for _, role := range roles {
+		if slices.Contains(p.Roles, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// roleRanks orders the roles, lowest first.
+var roleRanks = map[string]int{"user": 1, "moderator": 2, "admin": 3}
+
+// Outranks reports whether role is above target, which is what acting on
+// another account's standing takes. Unknown roles outrank nothing and are
+// outranked by nothing.
+func Outranks(role, target string) bool {
+
+	rank, ok := roleRanks[role]
+	target_rank, target_ok := roleRanks[target]
+	return ok && target_ok && rank > target_rank
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal stored by WithPrincipal, if any.
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestParseAccessToken(t *testing.T) {
+	userID := uuid.New()
+
+	token, err := MakeJWT(userID, "secret", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := ParseAccessToken(token, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.UserID != userID || p.TokenType != TokenAccess {
+		t.Errorf("got %+v", p)
+	}
+
+	expired, _ := MakeJWT(userID, "secret", -time.Minute)
+	if _, err := ParseAccessToken(expired, "secret"); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("expired token: err = %v", err)
+	}
+}
+
+func TestPrincipalHasRole(t *testing.T) {
+	p := Principal{Roles: []string{"moderator"}}
+	if !p.HasRole("moderator", "admin") {
+		t.Error("moderator should match")
+	}
+	if p.HasRole("admin") {
+		t.Error("moderator isn't admin")
+	}
+}
+
+func TestOutranks(t *testing.T) {
+
+	tests := []struct {
+		role, target string
+		want         bool
+	}{
+		{"moderator", "user", true},
+		{"moderator", "moderator", false},
+		{"moderator", "admin", false},
+		{"admin", "user", true},
+		{"admin", "moderator", true},
+		{"admin", "admin", false},
+		{"user", "user", false},
+		{"admin", "superuser", false},
+		{"", "user", false},
+	}
+
+	for _, tc := range tests {
+		if got := Outranks(tc.role, tc.target); got != tc.want {
+			t.Errorf("Outranks(%q, %q) = %v, want %v", tc.role, tc.target, got, tc.want)
+		}
+	}
+}
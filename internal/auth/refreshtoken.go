@@ -23,10 +23,13 @@ func GetBearerRefreshToken(headers http.Header) (string, error) {
 	
 	unclean_token := headers.Get("Authorization")
 	if unclean_token == "" {
-		return "", errors.New("refresh token doesn't exist")
+		return "", ErrNoToken
 	}
 
 	values := strings.Fields(unclean_token)
+	if len(values) != 2 {
+		return "", errors.New("malformed Authorization header")
+	}
 
 	if values[0] != "Bearer" {
 		return "", errors.New("no Bearer format")
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWebhookVerifier(t *testing.T) {
+
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"id":"evt_1","event":"user.upgraded"}`)
+
+	v := &WebhookVerifier{
+		Keys:      []string{"new-key", "old-key"},
+		Header:    "X-Signature",
+		Tolerance: 5 * time.Minute,
+		Now:       func() time.Time { return now },
+	}
+
+	tests := []struct {
+		name    string
+		header  string
+		body    []byte
+		wantErr error
+	}{
+		{
+			name:   "current key",
+			header: SignWebhook("new-key", now, body),
+			body:   body,
+		},
+		{
+			name:   "key being rotated out",
+			header: SignWebhook("old-key", now.Add(-time.Minute), body),
+			body:   body,
+		},
+		{
+			name:   "several signatures, one valid",
+			header: SignWebhook("retired-key", now, body) + ",v1=" + webhookMAC("new-key", "1700000000", body),
+			body:   body,
+		},
+		{
+			name:    "unknown key",
+			header:  SignWebhook("retired-key", now, body),
+			body:    body,
+			wantErr: ErrSignatureMismatch,
+		},
+		{
+			name:    "tampered body",
+			header:  SignWebhook("new-key", now, body),
+			body:    []byte(`{"id":"evt_1","event":"user.refunded"}`),
+			wantErr: ErrSignatureMismatch,
+		},
+		{
+			name:    "replayed too late",
+			header:  SignWebhook("new-key", now.Add(-10*time.Minute), body),
+			body:    body,
+			wantErr: ErrSignatureExpired,
+		},
+		{
+			name:    "timestamp in the future",
+			header:  SignWebhook("new-key", now.Add(10*time.Minute), body),
+			body:    body,
+			wantErr: ErrSignatureExpired,
+		},
+		{
+			name:    "missing",
+			header:  "",
+			body:    body,
+			wantErr: ErrMissingSignature,
+		},
+		{
+			name:    "no timestamp",
+			header:  "v1=abcd",
+			body:    body,
+			wantErr: ErrMalformedSignature,
+		},
+		{
+			name:    "bad hex",
+			header:  "t=1700000000,v1=zz",
+			body:    body,
+			wantErr: ErrMalformedSignature,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			headers := http.Header{}
+			if tc.header != "" {
+				headers.Set("X-Signature", tc.header)
+			}
+
+			err := v.Verify(headers, tc.body)
+			if tc.wantErr == nil && err != nil {
+				t.Fatalf("Verify() error = %v, want nil", err)
+			}
+			if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+				t.Fatalf("Verify() error = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
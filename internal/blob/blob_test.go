@@ -0,0 +1,127 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newS3Stub returns a tiny in-memory stand-in for an S3-compatible server
+// that understands path-style PUT, GET and DELETE.
+func newS3Stub(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=access/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch r.Method {
+		case http.MethodPut:
+			data, _ := io.ReadAll(r.Body)
+			if r.Header.Get("X-Amz-Content-Sha256") != sha256Hex(data) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			objects[r.URL.Path] = data
+			w.WriteHeader(http.StatusOK)
+		case http.MethodGet:
+			data, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(data)
+		case http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestStores(t *testing.T) {
+	srv := newS3Stub(t)
+
+	local, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+
+	s3, err := NewS3Store(S3Config{
+		Endpoint:  srv.URL,
+		Bucket:    "chirpy",
+		AccessKey: "access",
+		SecretKey: "secret",
+	})
+	if err != nil {
+		t.Fatalf("NewS3Store() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		store Store
+	}{
+		{name: "Local", store: local},
+		{name: "S3", store: s3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+
+			err := tt.store.Put(ctx, "media/a/original.png", strings.NewReader("pixels"), "image/png")
+			if err != nil {
+				t.Fatalf("Put() error = %v", err)
+			}
+
+			rc, err := tt.store.Get(ctx, "media/a/original.png")
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			got, _ := io.ReadAll(rc)
+			rc.Close()
+			if string(got) != "pixels" {
+				t.Errorf("Get() = %q, want %q", got, "pixels")
+			}
+
+			err = tt.store.Delete(ctx, "media/a/original.png")
+			if err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+
+			_, err = tt.store.Get(ctx, "media/a/original.png")
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get() after Delete() error = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestLocalStoreRejectsEscapingKeys(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+
+	for _, key := range []string{"../outside", "/etc/passwd", "."} {
+		err := store.Put(context.Background(), key, strings.NewReader("x"), "")
+		if err == nil {
+			t.Errorf("Put(%q) expected error, got nil", key)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+// Package chirptext measures and validates chirp bodies.
+//
+// Length is counted in user-perceived characters (grapheme clusters), so an
+// emoji with skin tone or a flag counts as one character no matter how many
+// code points or bytes it takes. Links count as URLWeight characters
+// regardless of how long they are.
+package chirptext
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+	"golang.org/x/text/unicode/norm"
+)
+
+// URLWeight is the number of characters every link counts as.
+const URLWeight = 23
+
+type Code string
+
+const (
+	CodeEmpty            Code = "empty"
+	CodeTooLong          Code = "too_long"
+	CodeInvalidEncoding  Code = "invalid_encoding"
+	CodeInvalidCharacter Code = "invalid_character"
+)
+
+// Error describes why a body was refused.
+type Error struct {
+	Code Code
+	// Length and Limit are set for CodeTooLong.
+	Length int
+	Limit  int
+	// Char and Offset are set for CodeInvalidCharacter. Offset is a byte
+	// offset into the normalized body.
+	Char   rune
+	Offset int
+}
+
+func (e *Error) Error() string {
+	switch e.Code {
+	case CodeEmpty:
+		return "chirp is empty"
+	case CodeTooLong:
+		return fmt.Sprintf("chirp is too long: %d characters, the limit is %d", e.Length, e.Limit)
+	case CodeInvalidEncoding:
+		return "chirp is not valid UTF-8"
+	case CodeInvalidCharacter:
+		return fmt.Sprintf("chirp contains a character that isn't allowed: %U", e.Char)
+	}
+	return string(e.Code)
+}
+
+var urlPattern = regexp.MustCompile(`(?i)(?:https?://|www\.)[^\s]*[^\s.,!?;:'")\]}]`)
+
+// lineEndings turns Windows and old Mac line endings into plain newlines.
+var lineEndings = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+// Normalize trims surrounding whitespace, turns every line ending into \n
+// and puts s in NFC form, so that the same text is always stored and
+// measured the same way.
+func Normalize(s string) string {
+	return norm.NFC.String(strings.TrimSpace(lineEndings.Replace(s)))
+}
+
+// Length counts the characters of s the way the limit sees them.
+func Length(s string) int {
+
+	n := 0
+	last := 0
+	for _, loc := range urlPattern.FindAllStringIndex(s, -1) {
+		n += uniseg.GraphemeClusterCount(s[last:loc[0]]) + URLWeight
+		last = loc[1]
+	}
+	return n + uniseg.GraphemeClusterCount(s[last:])
+}
+
+// Validate normalizes body and checks it against limit. It returns the
+// normalized body, which is what should be stored. Errors are always *Error.
+func Validate(body string, limit int) (string, error) {
+
+	if !utf8.ValidString(body) {
+		return "", &Error{Code: CodeInvalidEncoding}
+	}
+
+	body = Normalize(body)
+	if body == "" {
+		return "", &Error{Code: CodeEmpty}
+	}
+
+	offset := 0
+	visible := false
+	rest := body
+	state := -1
+	for len(rest) > 0 {
+		var cluster string
+		cluster, rest, _, state = uniseg.FirstGraphemeClusterInString(rest, state)
+		for i, c := range cluster {
+			if !allowed(c, cluster) {
+				return "", &Error{Code: CodeInvalidCharacter, Char: c, Offset: offset + i}
+			}
+			if !unicode.IsSpace(c) && c != zeroWidthJoiner && c != zeroWidthNonJoiner {
+				visible = true
+			}
+		}
+		offset += len(cluster)
+	}
+	if !visible {
+		return "", &Error{Code: CodeEmpty}
+	}
+
+	length := Length(body)
+	if length > limit {
+		return "", &Error{Code: CodeTooLong, Length: length, Limit: limit}
+	}
+
+	return body, nil
+}
+
+const (
+	zeroWidthNonJoiner = '\u200c'
+	zeroWidthJoiner    = '\u200d'
+	blackFlag          = '\U0001F3F4'
+)
+
+// invisibleLetters are letters that render as blank space and are used to
+// make chirps look empty or to dodge the filter.
+var invisibleLetters = map[rune]struct{}{
+	'\u115f': {}, // hangul choseong filler
+	'\u1160': {}, // hangul jungseong filler
+	'\u3164': {}, // hangul filler
+	'\uffa0': {}, // halfwidth hangul filler
+}
+
+// allowed reports whether c may appear in a chirp as part of cluster.
+func allowed(c rune, cluster string) bool {
+
+	switch {
+	case c == '\n' || c == '\t':
+		return true
+	case c == zeroWidthJoiner || c == zeroWidthNonJoiner:
+		// needed by emoji sequences and by several scripts
+		return true
+	case c >= '\U000E0020' && c <= '\U000E007F':
+		// tag characters only belong in subdivision flags such as Scotland's
+		first, _ := utf8.DecodeRuneInString(cluster)
+		return first == blackFlag
+	case unicode.In(c, unicode.Cc, unicode.Cf, unicode.Co, unicode.Zl, unicode.Zp):
+		return false
+	case c == utf8.RuneError:
+		return false
+	}
+
+	_, invisible := invisibleLetters[c]
+	return !invisible
+}
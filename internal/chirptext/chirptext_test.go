@@ -0,0 +1,108 @@
+package chirptext
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLength(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want int
+	}{
+		{"ascii", "hello", 5},
+		{"accent decomposed", "cafe\u0301", 4},
+		{"emoji with skin tone", "\U0001F44D\U0001F3FD", 1},
+		{"family zwj sequence", "\U0001F468\u200d\U0001F469\u200d\U0001F467", 1},
+		{"flag", "\U0001F1F3\U0001F1F5", 1},
+		{"url", "see https://example.com/a/very/long/path?with=query", 4 + URLWeight},
+		{"url with trailing period", "go to www.example.com.", 6 + URLWeight + 1},
+		{"two urls", "http://a.io http://b.io", 2*URLWeight + 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Length(tc.in)
+			if got != tc.want {
+				t.Errorf("Length(%q) = %d, want %d", tc.in, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		limit    int
+		want     string
+		wantCode Code
+	}{
+		{"fits", "hello world", 140, "hello world", ""},
+		{"normalizes to nfc", "  cafe\u0301  ", 140, "caf\u00e9", ""},
+		{"140 emoji fit", strings.Repeat("\U0001F600", 140), 140, strings.Repeat("\U0001F600", 140), ""},
+		{"141 chars", strings.Repeat("a", 141), 140, "", CodeTooLong},
+		{"long url counts as fixed weight", "https://example.com/" + strings.Repeat("x", 200), 140, "https://example.com/" + strings.Repeat("x", 200), ""},
+		{"newlines allowed", "line one\nline two", 140, "line one\nline two", ""},
+		{"line endings become newlines", "one\r\ntwo\rthree\r\n", 140, "one\ntwo\nthree", ""},
+		{"zwj emoji allowed", "\U0001F469\u200d\U0001F4BB", 140, "\U0001F469\u200d\U0001F4BB", ""},
+		{"subdivision flag allowed", "\U0001F3F4\U000E0067\U000E0062\U000E0073\U000E0063\U000E0074\U000E007F", 140, "\U0001F3F4\U000E0067\U000E0062\U000E0073\U000E0063\U000E0074\U000E007F", ""},
+		{"empty", "   ", 140, "", CodeEmpty},
+		{"only zero width", "\u200d\u200c", 140, "", CodeEmpty},
+		{"zero width space", "a\u200bb", 140, "", CodeInvalidCharacter},
+		{"bidi override", "abc\u202edef", 140, "", CodeInvalidCharacter},
+		{"control character", "a\x07b", 140, "", CodeInvalidCharacter},
+		{"hangul filler", "\u3164", 140, "", CodeInvalidCharacter},
+		{"stray tag character", "a\U000E0061", 140, "", CodeInvalidCharacter},
+		{"invalid utf8", "a\xffb", 140, "", CodeInvalidEncoding},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Validate(tc.in, tc.limit)
+			if tc.wantCode == "" {
+				if err != nil {
+					t.Fatalf("Validate(%q) error = %v", tc.in, err)
+				}
+				if got != tc.want {
+					t.Errorf("Validate(%q) = %q, want %q", tc.in, got, tc.want)
+				}
+				return
+			}
+
+			var verr *Error
+			if !errors.As(err, &verr) {
+				t.Fatalf("Validate(%q) error = %v, want *Error", tc.in, err)
+			}
+			if verr.Code != tc.wantCode {
+				t.Errorf("Validate(%q) code = %s, want %s", tc.in, verr.Code, tc.wantCode)
+			}
+		})
+	}
+}
+
+func TestValidateTooLongReportsLength(t *testing.T) {
+	_, err := Validate(strings.Repeat("\u00e9", 150), 140)
+
+	var verr *Error
+	if !errors.As(err, &verr) {
+		t.Fatalf("error = %v, want *Error", err)
+	}
+	if verr.Length != 150 || verr.Limit != 140 {
+		t.Errorf("got length %d limit %d, want 150 and 140", verr.Length, verr.Limit)
+	}
+}
+
+func TestValidateInvalidCharacterOffset(t *testing.T) {
+	_, err := Validate("h\u00e9\u200bllo", 140)
+
+	var verr *Error
+	if !errors.As(err, &verr) {
+		t.Fatalf("error = %v, want *Error", err)
+	}
+	if verr.Char != '\u200b' || verr.Offset != 3 {
+		t.Errorf("got %U at %d, want U+200B at 3", verr.Char, verr.Offset)
+	}
+}
@@ -0,0 +1,469 @@
+// Package config loads Chirpy's settings.
+//
+// Every setting has a key such as db_url. Values are merged from, lowest
+// precedence first:
+//
+//   - the default in the field's tag
+//   - a YAML file named by -config or CHIRPY_CONFIG, using the keys as is
+//   - environment variables, the key in upper case (DB_URL); NAME_FILE reads
+//     the value from a file instead, for secrets mounted by an orchestrator
+//   - command-line flags, the key with dashes (-db-url)
+//
+// The merged config is validated as a whole, so every problem is reported
+// at once instead of one per restart.
+package config
+
+import (
+	"encoding"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/frozendolphin/Chirpy/internal/ratelimit"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds every setting. Field tags drive loading:
+//
+//	conf      the key; fields that implement encoding.TextUnmarshaler parse themselves
+//	env       environment variables to read, in order; defaults to the key in upper case
+//	default   value when nothing else sets one
+//	required  must end up non-empty
+//	secret    never printed
+//	oneof     comma separated list of allowed values
+//	min       smallest allowed number or duration; durations must otherwise be positive
+//	max       largest allowed number
+//	minlen    shortest allowed string
+type Config struct {
+	Addr     string `conf:"addr" default:":8080" help:"address to listen on"`
+	LogLevel string `conf:"log_level" default:"info" oneof:"debug,info,warn,error" help:"least severe log level written"`
+	Platform string `conf:"platform" default:"prod" oneof:"dev,prod" help:"dev enables destructive admin endpoints"`
+	DBURL    string `conf:"db_url" required:"true" secret:"true" help:"Postgres connection string"`
+	// Secret signs JWTs; HS256 wants at least 256 bits of key.
+	Secret string `conf:"secret" required:"true" secret:"true" minlen:"32" help:"JWT signing key"`
+
+	PolkaWebhookSecrets []string `conf:"polka_webhook_secrets" env:"POLKA_WEBHOOK_SECRETS,POLKA_KEY" secret:"true" help:"comma separated Polka signing keys"`
+
+	MediaStore    string `conf:"media_store" default:"local" oneof:"local,s3" help:"where uploaded media is kept"`
+	MediaDir      string `conf:"media_dir" default:"media" help:"directory for the local media store"`
+	MediaMaxBytes int64  `conf:"media_max_bytes" default:"5242880" min:"1" help:"largest accepted upload"`
+	// uploads are stored before they are attached, so they need a cap of their own
+	MediaMaxPending int    `conf:"media_max_pending" default:"20" min:"1" help:"uploads a user may have that aren't attached to a chirp yet"`
+	S3Endpoint      string `conf:"s3_endpoint" help:"S3 endpoint URL"`
+	S3Bucket        string `conf:"s3_bucket" help:"S3 bucket"`
+	S3Region        string `conf:"s3_region" help:"S3 region"`
+	S3AccessKey     string `conf:"s3_access_key" secret:"true" help:"S3 access key"`
+	S3SecretKey     string `conf:"s3_secret_key" secret:"true" help:"S3 secret key"`
+
+	ReadHeaderTimeout time.Duration `conf:"read_header_timeout" default:"5s" help:"time allowed to read request headers"`
+	ReadTimeout       time.Duration `conf:"read_timeout" default:"30s" help:"time allowed to read a whole request, body included"`
+	WriteTimeout      time.Duration `conf:"write_timeout" default:"30s" help:"time allowed to write a response"`
+	IdleTimeout       time.Duration `conf:"idle_timeout" default:"120s" help:"how long an idle keep-alive connection stays open"`
+	MaxHeaderBytes    int           `conf:"max_header_bytes" default:"1048576" min:"1024" help:"largest accepted request headers"`
+	MaxBodyBytes      int64         `conf:"max_body_bytes" default:"1048576" min:"1024" help:"largest accepted JSON request body"`
+	// DrainDelay gives load balancers time to see /api/healthz fail before
+	// the listener closes.
+	DrainDelay      time.Duration `conf:"drain_delay" default:"0s" min:"0s" help:"how long to report draining before refusing connections"`
+	ShutdownTimeout time.Duration `conf:"shutdown_timeout" default:"30s" help:"how long in-flight requests and jobs get to finish on shutdown"`
+
+	HealthCheckTimeout time.Duration `conf:"health_check_timeout" default:"2s" help:"how long each readiness check may take"`
+
+	TraceExporter    string  `conf:"trace_exporter" default:"none" oneof:"none,stdout,file,otlp" help:"where traces are sent"`
+	TraceFile        string  `conf:"trace_file" default:"traces.jsonl" help:"file the file exporter appends to"`
+	TraceEndpoint    string  `conf:"trace_endpoint" help:"OTLP/HTTP endpoint URL; defaults to OTEL_EXPORTER_OTLP_ENDPOINT or localhost:4318"`
+	TraceSampleRatio float64 `conf:"trace_sample_ratio" default:"1" min:"0" max:"1" help:"share of new traces recorded; callers' sampling decisions are kept"`
+
+	RateLimitStore     string          `conf:"rate_limit_store" default:"memory" oneof:"memory,postgres" help:"where rate limit state is kept; postgres shares it between replicas"`
+	RateLimitDefault   ratelimit.Limit `conf:"rate_limit_default" default:"600/1m" help:"requests per client to other API routes, or off"`
+	RateLimitLogin     ratelimit.Limit `conf:"rate_limit_login" default:"10/1m" help:"logins per IP address, or off"`
+	RateLimitSignup    ratelimit.Limit `conf:"rate_limit_signup" default:"10/1h" help:"sign-ups per IP address, or off"`
+	RateLimitChirps    ratelimit.Limit `conf:"rate_limit_chirps" default:"30/1m" help:"chirps posted per user, or off"`
+	RateLimitChirpsRed ratelimit.Limit `conf:"rate_limit_chirps_red" default:"120/1m" help:"chirps posted per Chirpy Red user, or off"`
+	// Behind a proxy every request comes from the proxy's address.
+	TrustForwardedFor bool `conf:"trust_forwarded_for" default:"false" help:"take client IPs from the last X-Forwarded-For entry"`
+
+	PasswordMinLength     int    `conf:"password_min_length" default:"8" min:"1" max:"72" help:"shortest password accepted for new passwords"`
+	PasswordCheckBreached bool   `conf:"password_check_breached" default:"true" help:"refuse new passwords on the breached password list"`
+	PasswordBreachedList  string `conf:"password_breached_list" help:"file of more breached passwords or their SHA-1 hashes, added to the built-in list"`
+
+	ReportHideThreshold int `conf:"report_hide_threshold" default:"5" min:"0" help:"reports that hide a chirp, 0 to never hide"`
+
+	RetentionInterval        time.Duration `conf:"retention_interval" default:"1h" help:"how often old rows are pruned"`
+	RefreshTokenRetention    time.Duration `conf:"refresh_token_retention" default:"168h" help:"keep refresh tokens this long after expiry or revocation"`
+	JobRetention             time.Duration `conf:"job_retention" default:"168h" help:"keep finished jobs this long"`
+	WebhookDeliveryRetention time.Duration `conf:"webhook_delivery_retention" default:"720h" help:"keep finished webhook deliveries this long"`
+	UnattachedMediaRetention time.Duration `conf:"unattached_media_retention" default:"24h" help:"delete uploads never attached to a chirp after this long"`
+}
+
+// Load reads the config from the environment, the config file and args
+// (without the program name). It returns flag.ErrHelp if -h was passed.
+func Load(args []string) (Config, error) {
+	return load(args, os.Getenv, os.ReadFile, os.Stderr)
+}
+
+type field struct {
+	key   string
+	env   []string
+	value reflect.Value
+	tag   reflect.StructTag
+}
+
+func fieldsOf(c *Config) []field {
+
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+
+	fields := []field{}
+	for i := range t.NumField() {
+		f := t.Field(i)
+		key := f.Tag.Get("conf")
+		env := []string{strings.ToUpper(key)}
+		if e := f.Tag.Get("env"); e != "" {
+			env = strings.Split(e, ",")
+		}
+		fields = append(fields, field{key: key, env: env, value: v.Field(i), tag: f.Tag})
+	}
+	return fields
+}
+
+// rawFlag records a flag's value as given, to be parsed like every other
+// source once the lower precedence ones are applied.
+type rawFlag struct {
+	value  string
+	set    bool
+	isBool bool
+}
+
+func (f *rawFlag) String() string   { return f.value }
+func (f *rawFlag) IsBoolFlag() bool { return f.isBool }
+
+func (f *rawFlag) Set(s string) error {
+	f.value = s
+	f.set = true
+	return nil
+}
+
+func load(args []string, getenv func(string) string, readFile func(string) ([]byte, error), output io.Writer) (Config, error) {
+
+	c := Config{}
+	fields := fieldsOf(&c)
+
+	fs := flag.NewFlagSet("chirpy", flag.ContinueOnError)
+	fs.SetOutput(output)
+	config_path := fs.String("config", getenv("CHIRPY_CONFIG"), "YAML config file")
+	flags := map[string]*rawFlag{}
+	for _, f := range fields {
+		raw := &rawFlag{isBool: f.value.Kind() == reflect.Bool}
+		flags[f.key] = raw
+		fs.Var(raw, strings.ReplaceAll(f.key, "_", "-"), f.tag.Get("help"))
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		return Config{}, err
+	}
+	if fs.NArg() > 0 {
+		return Config{}, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	var errs []error
+	set := func(f field, raw, source string) {
+		err := setValue(f.value, raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s (from %s): %w", f.key, source, err))
+		}
+	}
+
+	for _, f := range fields {
+		if d, ok := f.tag.Lookup("default"); ok {
+			set(f, d, "default")
+		}
+	}
+
+	if *config_path != "" {
+		file, err := readFile(*config_path)
+		if err != nil {
+			return Config{}, fmt.Errorf("couldn't read config file: %w", err)
+		}
+		values := map[string]any{}
+		err = yaml.Unmarshal(file, &values)
+		if err != nil {
+			return Config{}, fmt.Errorf("couldn't parse config file %s: %w", *config_path, err)
+		}
+		for key, v := range values {
+			i := slices.IndexFunc(fields, func(f field) bool { return f.key == key })
+			if i < 0 {
+				errs = append(errs, fmt.Errorf("%s: unknown key in %s", key, *config_path))
+				continue
+			}
+			if v == nil {
+				// a key with no value, like "s3_bucket:", leaves the setting alone
+				continue
+			}
+			set(fields[i], yamlString(v), *config_path)
+		}
+	}
+
+	for _, f := range fields {
+		raw, source, err := fromEnv(f.env, getenv, readFile)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.key, err))
+			continue
+		}
+		if source != "" {
+			set(f, raw, source)
+		}
+	}
+
+	for _, f := range fields {
+		if raw := flags[f.key]; raw.set {
+			set(f, raw.value, "-"+strings.ReplaceAll(f.key, "_", "-"))
+		}
+	}
+
+	if len(errs) > 0 {
+		return Config{}, errors.Join(errs...)
+	}
+	return c, c.Validate()
+}
+
+// fromEnv returns the first of names that is set, directly or as NAME_FILE,
+// and which variable it came from.
+func fromEnv(names []string, getenv func(string) string, readFile func(string) ([]byte, error)) (string, string, error) {
+
+	for _, name := range names {
+		value := getenv(name)
+		path := getenv(name + "_FILE")
+		switch {
+		case value != "" && path != "":
+			return "", "", fmt.Errorf("both %s and %s_FILE are set", name, name)
+		case value != "":
+			return value, name, nil
+		case path != "":
+			data, err := readFile(path)
+			if err != nil {
+				return "", "", fmt.Errorf("couldn't read %s_FILE: %w", name, err)
+			}
+			return strings.TrimSpace(string(data)), name + "_FILE", nil
+		}
+	}
+	return "", "", nil
+}
+
+func yamlString(v any) string {
+
+	if list, ok := v.([]any); ok {
+		items := []string{}
+		for _, item := range list {
+			if item != nil {
+				items = append(items, fmt.Sprint(item))
+			}
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(v)
+}
+
+func setValue(v reflect.Value, raw string) error {
+
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(raw))
+	}
+
+	if v.Type() == reflect.TypeFor[time.Duration]() {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration like 30s or 12h", raw)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number", raw)
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		v.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", raw)
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		items := []string{}
+		for _, item := range strings.Split(raw, ",") {
+			item = strings.TrimSpace(item)
+			if item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+// Validate checks every field against its tags and the settings against
+// each other.
+func (c *Config) Validate() error {
+
+	var errs []error
+	for _, f := range fieldsOf(c) {
+		err := checkField(f)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.key, err))
+		}
+	}
+
+	if c.MediaStore == "s3" {
+		for key, value := range map[string]string{
+			"s3_endpoint":   c.S3Endpoint,
+			"s3_bucket":     c.S3Bucket,
+			"s3_region":     c.S3Region,
+			"s3_access_key": c.S3AccessKey,
+			"s3_secret_key": c.S3SecretKey,
+		} {
+			if value == "" {
+				errs = append(errs, fmt.Errorf("%s: required when media_store is s3", key))
+			}
+		}
+	}
+
+	// map order is random; keep the report stable
+	slices.SortFunc(errs, func(a, b error) int { return strings.Compare(a.Error(), b.Error()) })
+	return errors.Join(errs...)
+}
+
+func empty(v reflect.Value) bool {
+	if v.Kind() == reflect.Slice {
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+func checkField(f field) error {
+
+	if f.tag.Get("required") == "true" && empty(f.value) {
+		return fmt.Errorf("is required (set %s)", f.env[0])
+	}
+
+	if f.value.Type() == reflect.TypeFor[time.Duration]() {
+		d := time.Duration(f.value.Int())
+		if min, ok := f.tag.Lookup("min"); ok {
+			least, _ := time.ParseDuration(min)
+			if d < least {
+				return fmt.Errorf("must be at least %s", least)
+			}
+			return nil
+		}
+		if d <= 0 {
+			return errors.New("must be a positive duration")
+		}
+		return nil
+	}
+
+	switch f.value.Kind() {
+	case reflect.String:
+		s := f.value.String()
+		if oneof := f.tag.Get("oneof"); oneof != "" && !slices.Contains(strings.Split(oneof, ","), s) {
+			return fmt.Errorf("%q must be one of %s", s, strings.ReplaceAll(oneof, ",", ", "))
+		}
+		if minlen := f.tag.Get("minlen"); minlen != "" && s != "" {
+			n, _ := strconv.Atoi(minlen)
+			if len(s) < n {
+				return fmt.Errorf("must be at least %d characters", n)
+			}
+		}
+	case reflect.Int, reflect.Int64:
+		if min := f.tag.Get("min"); min != "" {
+			n, _ := strconv.ParseInt(min, 10, 64)
+			if f.value.Int() < n {
+				return fmt.Errorf("must be at least %d", n)
+			}
+		}
+	case reflect.Float64:
+		n := f.value.Float()
+		if min := f.tag.Get("min"); min != "" {
+			least, _ := strconv.ParseFloat(min, 64)
+			if n < least {
+				return fmt.Errorf("must be at least %s", min)
+			}
+		}
+		if max := f.tag.Get("max"); max != "" {
+			most, _ := strconv.ParseFloat(max, 64)
+			if n > most {
+				return fmt.Errorf("must be at most %s", max)
+			}
+		}
+	}
+	return nil
+}
+
+// Redacted lists every setting and its value, one per line, with secrets
+// replaced so the output is safe to log.
+func (c *Config) Redacted() string {
+
+	var b strings.Builder
+	for _, kv := range c.redacted() {
+		fmt.Fprintf(&b, "%s = %s\n", kv[0], kv[1])
+	}
+	return b.String()
+}
+
+// LogValue logs the settings as a group, redacted like Redacted.
+func (c Config) LogValue() slog.Value {
+
+	attrs := []slog.Attr{}
+	for _, kv := range c.redacted() {
+		attrs = append(attrs, slog.String(kv[0], kv[1]))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// redacted returns each key and its printable value.
+func (c *Config) redacted() [][2]string {
+
+	res := [][2]string{}
+	for _, f := range fieldsOf(c) {
+		value := fmt.Sprint(f.value.Interface())
+		if f.value.Kind() == reflect.Slice {
+			value = strings.Join(f.value.Interface().([]string), ",")
+		}
+		switch {
+		case f.tag.Get("secret") == "true" && !empty(f.value):
+			value = "[redacted]"
+		case value == "":
+			value = "(unset)"
+		}
+		res = append(res, [2]string{f.key, value})
+	}
+	return res
+}
+
+// SlogLevel is LogLevel as a slog.Level.
+func (c *Config) SlogLevel() slog.Level {
+
+	var level slog.Level
+	// Validate has already checked it's one slog knows
+	level.UnmarshalText([]byte(c.LogLevel))
+	return level
+}
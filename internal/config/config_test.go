@@ -0,0 +1,254 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/frozendolphin/Chirpy/internal/ratelimit"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+type fakeEnv struct {
+	vars  map[string]string
+	files map[string]string
+}
+
+func (e fakeEnv) getenv(name string) string {
+	return e.vars[name]
+}
+
+func (e fakeEnv) readFile(path string) ([]byte, error) {
+	data, ok := e.files[path]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return []byte(data), nil
+}
+
+func (e fakeEnv) load(args ...string) (Config, error) {
+	return load(args, e.getenv, e.readFile, io.Discard)
+}
+
+func TestPrecedence(t *testing.T) {
+
+	env := fakeEnv{
+		vars: map[string]string{
+			"CHIRPY_CONFIG":         "chirpy.yaml",
+			"DB_URL":                "postgres://env",
+			"SECRET_FILE":           "/run/secrets/jwt",
+			"REPORT_HIDE_THRESHOLD": "7",
+		},
+		files: map[string]string{
+			"/run/secrets/jwt": testSecret + "\n",
+			"chirpy.yaml": `
+addr: ":9090"
+db_url: postgres://file
+report_hide_threshold: 3
+retention_interval: 30m
+polka_webhook_secrets:
+  - new-key
+  - old-key
+`,
+		},
+	}
+
+	c, err := env.load("-addr", ":7070")
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+
+	checks := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"default", c.MediaDir, "media"},
+		{"file over default", c.RetentionInterval, 30 * time.Minute},
+		{"file list", strings.Join(c.PolkaWebhookSecrets, ","), "new-key,old-key"},
+		{"env over file", c.DBURL, "postgres://env"},
+		{"env over file, number", c.ReportHideThreshold, 7},
+		{"_FILE", c.Secret, testSecret},
+		{"flag over file", c.Addr, ":7070"},
+		{"text unmarshaler", c.RateLimitLogin, ratelimit.Limit{Requests: 10, Per: time.Minute}},
+	}
+	for _, check := range checks {
+		if check.got != check.want {
+			t.Errorf("%s: got %v, want %v", check.name, check.got, check.want)
+		}
+	}
+}
+
+func TestLegacyPolkaKey(t *testing.T) {
+
+	env := fakeEnv{vars: map[string]string{
+		"DB_URL":    "postgres://env",
+		"SECRET":    testSecret,
+		"POLKA_KEY": "legacy",
+	}}
+
+	c, err := env.load()
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	if len(c.PolkaWebhookSecrets) != 1 || c.PolkaWebhookSecrets[0] != "legacy" {
+		t.Errorf("PolkaWebhookSecrets = %v, want [legacy]", c.PolkaWebhookSecrets)
+	}
+}
+
+func TestValidation(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		vars  map[string]string
+		args  []string
+		wants []string
+	}{
+		{
+			name:  "missing required",
+			vars:  map[string]string{},
+			wants: []string{"db_url: is required", "secret: is required"},
+		},
+		{
+			name:  "short secret",
+			vars:  map[string]string{"DB_URL": "x", "SECRET": "hunter2"},
+			wants: []string{"secret: must be at least 32 characters"},
+		},
+		{
+			name: "bad formats",
+			vars: map[string]string{"DB_URL": "x", "SECRET": testSecret, "MEDIA_MAX_BYTES": "5MB"},
+			args: []string{"-retention-interval", "hourly", "-platform", "staging"},
+			wants: []string{
+				`media_max_bytes (from MEDIA_MAX_BYTES): "5MB" is not a whole number`,
+				`retention_interval (from -retention-interval): "hourly" is not a duration`,
+			},
+		},
+		{
+			name:  "not one of",
+			vars:  map[string]string{"DB_URL": "x", "SECRET": testSecret, "PLATFORM": "staging"},
+			wants: []string{`platform: "staging" must be one of dev, prod`},
+		},
+		{
+			name:  "s3 needs its settings",
+			vars:  map[string]string{"DB_URL": "x", "SECRET": testSecret, "MEDIA_STORE": "s3", "S3_BUCKET": "chirpy"},
+			wants: []string{"s3_endpoint: required", "s3_secret_key: required"},
+		},
+		{
+			name:  "durations",
+			vars:  map[string]string{"DB_URL": "x", "SECRET": testSecret, "WRITE_TIMEOUT": "0s", "DRAIN_DELAY": "-1s"},
+			wants: []string{"write_timeout: must be a positive duration", "drain_delay: must be at least 0s"},
+		},
+		{
+			name:  "ratio",
+			vars:  map[string]string{"DB_URL": "x", "SECRET": testSecret, "TRACE_SAMPLE_RATIO": "1.5"},
+			wants: []string{"trace_sample_ratio: must be at most 1"},
+		},
+		{
+			name:  "limit",
+			vars:  map[string]string{"DB_URL": "x", "SECRET": testSecret, "RATE_LIMIT_LOGIN": "lots"},
+			wants: []string{`rate_limit_login (from RATE_LIMIT_LOGIN): "lots" isn't a limit`},
+		},
+		{
+			name:  "value and file",
+			vars:  map[string]string{"DB_URL": "x", "SECRET": testSecret, "DB_URL_FILE": "/run/secrets/db"},
+			wants: []string{"both DB_URL and DB_URL_FILE are set"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := fakeEnv{vars: tc.vars}.load(tc.args...)
+			if err == nil {
+				t.Fatal("load() succeeded, want an error")
+			}
+			for _, want := range tc.wants {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q doesn't mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestUnknownFileKey(t *testing.T) {
+
+	env := fakeEnv{
+		vars:  map[string]string{"DB_URL": "x", "SECRET": testSecret, "CHIRPY_CONFIG": "c.yaml"},
+		files: map[string]string{"c.yaml": "db_uri: postgres://typo\n"},
+	}
+
+	_, err := env.load()
+	if err == nil || !strings.Contains(err.Error(), "db_uri: unknown key") {
+		t.Fatalf("load() error = %v, want unknown key", err)
+	}
+}
+
+func TestEmptyFileValue(t *testing.T) {
+
+	env := fakeEnv{
+		vars: map[string]string{"SECRET": testSecret, "CHIRPY_CONFIG": "c.yaml"},
+		files: map[string]string{"c.yaml": "db_url:\nmedia_store: s3\ns3_bucket:\n" +
+			"s3_endpoint: https://s3.example.com\ns3_region: us-east-1\ns3_access_key: key\ns3_secret_key: secret\n" +
+			"polka_webhook_secrets:\n  - one\n  -\n  - two\n"},
+	}
+
+	_, err := env.load()
+	if err == nil {
+		t.Fatal("load() succeeded, want the empty keys treated as unset")
+	}
+	for _, want := range []string{"db_url: is required", "s3_bucket: required"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q doesn't mention %q", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "<nil>") {
+		t.Errorf("error %q mentions <nil>", err)
+	}
+
+	env.vars["DB_URL"] = "postgres://db"
+	env.files["c.yaml"] = strings.Replace(env.files["c.yaml"], "s3_bucket:", "s3_bucket: chirpy", 1)
+	c, err := env.load()
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	if got := strings.Join(c.PolkaWebhookSecrets, ","); got != "one,two" {
+		t.Errorf("PolkaWebhookSecrets = %q, want %q", got, "one,two")
+	}
+}
+
+func TestHelp(t *testing.T) {
+
+	_, err := fakeEnv{}.load("-h")
+	if !errors.Is(err, flag.ErrHelp) {
+		t.Fatalf("load(-h) error = %v, want flag.ErrHelp", err)
+	}
+}
+
+func TestRedacted(t *testing.T) {
+
+	c, err := fakeEnv{vars: map[string]string{
+		"DB_URL":                "postgres://user:pw@db/chirpy",
+		"SECRET":                testSecret,
+		"POLKA_WEBHOOK_SECRETS": "a,b",
+	}}.load()
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+
+	out := c.Redacted()
+	for _, secret := range []string{"pw@db", testSecret, "a,b"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("Redacted() leaks %q:\n%s", secret, out)
+		}
+	}
+	for _, want := range []string{"db_url = [redacted]", "addr = :8080", "s3_secret_key = (unset)", "retention_interval = 1h0m0s"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Redacted() is missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -7,29 +7,82 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 )
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, visibility, status, published_at)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    'published',
+    NOW()
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, status, publish_at, published_at, visibility, hidden_at, edited_at
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body       string
+	UserID     uuid.UUID
+	Visibility string
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID, arg.Visibility)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+		&i.PublishedAt,
+		&i.Visibility,
+		&i.HiddenAt,
+		&i.EditedAt,
+	)
+	return i, err
+}
+
+const createUnpublishedChirp = `-- name: CreateUnpublishedChirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, visibility, status, publish_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING id, created_at, updated_at, body, user_id, status, publish_at, published_at, visibility, hidden_at, edited_at
+`
+
+type CreateUnpublishedChirpParams struct {
+	Body       string
+	UserID     uuid.UUID
+	Visibility string
+	Status     string
+	PublishAt  sql.NullTime
+}
+
+func (q *Queries) CreateUnpublishedChirp(ctx context.Context, arg CreateUnpublishedChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, createUnpublishedChirp,
+		arg.Body,
+		arg.UserID,
+		arg.Visibility,
+		arg.Status,
+		arg.PublishAt,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,6 +90,12 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+		&i.PublishedAt,
+		&i.Visibility,
+		&i.HiddenAt,
+		&i.EditedAt,
 	)
 	return i, err
 }
@@ -51,13 +110,85 @@ func (q *Queries) DeleteAChirp(ctx context.Context, id uuid.UUID) error {
 	return err
 }
 
+const deleteUnpublishedChirp = `-- name: DeleteUnpublishedChirp :execrows
+DELETE FROM chirps
+WHERE id = $1 AND user_id = $2 AND status <> 'published'
+`
+
+type DeleteUnpublishedChirpParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) DeleteUnpublishedChirp(ctx context.Context, arg DeleteUnpublishedChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUnpublishedChirp, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const editChirp = `-- name: EditChirp :one
+UPDATE chirps
+SET updated_at = NOW(), edited_at = NOW(), body = $1
+WHERE id = $2 AND user_id = $3 AND status = 'published'
+RETURNING id, created_at, updated_at, body, user_id, status, publish_at, published_at, visibility, hidden_at, edited_at
+`
+
+type EditChirpParams struct {
+	Body   string
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+func (q *Queries) EditChirp(ctx context.Context, arg EditChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, editChirp, arg.Body, arg.ID, arg.UserID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+		&i.PublishedAt,
+		&i.Visibility,
+		&i.HiddenAt,
+		&i.EditedAt,
+	)
+	return i, err
+}
+
 const getAChirp = `-- name: GetAChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-where id = $1
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, published_at, visibility, hidden_at, edited_at FROM chirps
+where chirps.id = $1 AND chirps.status = 'published'
+    AND (
+        chirps.user_id = $2
+        OR (chirps.hidden_at IS NULL AND NOT EXISTS (
+            SELECT 1 FROM users
+            WHERE users.id = chirps.user_id
+                AND (users.banned_at IS NOT NULL OR users.shadow_limited OR users.suspended_until > NOW())
+        ))
+    )
+    AND (
+        chirps.visibility IN ('public', 'unlisted')
+        OR chirps.user_id = $2
+        OR (chirps.visibility = 'followers' AND EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = $2 AND follows.followee_id = chirps.user_id
+        ))
+    )
 `
 
-func (q *Queries) GetAChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, getAChirp, id)
+type GetAChirpParams struct {
+	ID       uuid.UUID
+	ViewerID uuid.UUID
+}
+
+// Like GetAllChirps, but unlisted chirps can be opened directly.
+func (q *Queries) GetAChirp(ctx context.Context, arg GetAChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getAChirp, arg.ID, arg.ViewerID)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -65,17 +196,45 @@ func (q *Queries) GetAChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+		&i.PublishedAt,
+		&i.Visibility,
+		&i.HiddenAt,
+		&i.EditedAt,
 	)
 	return i, err
 }
 
 const getAllChirps = `-- name: GetAllChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-ORDER BY created_at ASC
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, published_at, visibility, hidden_at, edited_at FROM chirps
+WHERE chirps.status = 'published'
+    AND (
+        chirps.user_id = $1
+        OR (chirps.hidden_at IS NULL AND NOT EXISTS (
+            SELECT 1 FROM users
+            WHERE users.id = chirps.user_id
+                AND (users.banned_at IS NOT NULL OR users.shadow_limited OR users.suspended_until > NOW())
+        ))
+    )
+    AND (
+        chirps.visibility = 'public'
+        OR chirps.user_id = $1
+        OR (chirps.visibility = 'followers' AND EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = $1 AND follows.followee_id = chirps.user_id
+        ))
+    )
+ORDER BY published_at ASC
 `
 
-func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirps)
+// Only returns chirps the viewer may see: public ones, their own, and
+// followers-only ones of users they follow. Unlisted chirps are left out.
+// Chirps hidden by moderation, and chirps of banned, suspended or
+// shadow-limited users, are only shown to their author.
+// Anonymous viewers pass the nil uuid.
+func (q *Queries) GetAllChirps(ctx context.Context, viewerID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getAllChirps, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -89,6 +248,12 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.PublishedAt,
+			&i.Visibility,
+			&i.HiddenAt,
+			&i.EditedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -103,14 +268,62 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 	return items, nil
 }
 
+const getChirpByID = `-- name: GetChirpByID :one
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, published_at, visibility, hidden_at, edited_at FROM chirps
+WHERE id = $1
+`
+
+// Ignores visibility; only for moderation and other internal lookups.
+func (q *Queries) GetChirpByID(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpByID, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+		&i.PublishedAt,
+		&i.Visibility,
+		&i.HiddenAt,
+		&i.EditedAt,
+	)
+	return i, err
+}
+
 const getChirpsFromAuthor = `-- name: GetChirpsFromAuthor :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-where user_id = $1
-ORDER BY created_at ASC
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, published_at, visibility, hidden_at, edited_at FROM chirps
+where chirps.user_id = $1 AND chirps.status = 'published'
+    AND (
+        chirps.user_id = $2
+        OR (chirps.hidden_at IS NULL AND NOT EXISTS (
+            SELECT 1 FROM users
+            WHERE users.id = chirps.user_id
+                AND (users.banned_at IS NOT NULL OR users.shadow_limited OR users.suspended_until > NOW())
+        ))
+    )
+    AND (
+        chirps.visibility = 'public'
+        OR chirps.user_id = $2
+        OR (chirps.visibility = 'followers' AND EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = $2 AND follows.followee_id = chirps.user_id
+        ))
+    )
+ORDER BY published_at ASC
 `
 
-func (q *Queries) GetChirpsFromAuthor(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsFromAuthor, userID)
+type GetChirpsFromAuthorParams struct {
+	UserID   uuid.UUID
+	ViewerID uuid.UUID
+}
+
+// Same visibility rules as GetAllChirps: unlisted chirps are left out
+// unless the viewer wrote them.
+func (q *Queries) GetChirpsFromAuthor(ctx context.Context, arg GetChirpsFromAuthorParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsFromAuthor, arg.UserID, arg.ViewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -124,6 +337,12 @@ func (q *Queries) GetChirpsFromAuthor(ctx context.Context, userID uuid.UUID) ([]
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.PublishedAt,
+			&i.Visibility,
+			&i.HiddenAt,
+			&i.EditedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -137,3 +356,162 @@ func (q *Queries) GetChirpsFromAuthor(ctx context.Context, userID uuid.UUID) ([]
 	}
 	return items, nil
 }
+
+const getUnpublishedChirpsForUser = `-- name: GetUnpublishedChirpsForUser :many
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, published_at, visibility, hidden_at, edited_at FROM chirps
+WHERE user_id = $1 AND status <> 'published'
+ORDER BY publish_at ASC NULLS LAST, created_at ASC
+`
+
+func (q *Queries) GetUnpublishedChirpsForUser(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getUnpublishedChirpsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.PublishedAt,
+			&i.Visibility,
+			&i.HiddenAt,
+			&i.EditedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const publishDueChirps = `-- name: PublishDueChirps :many
+UPDATE chirps
+SET updated_at = NOW(), status = 'published', published_at = NOW()
+WHERE id IN (
+    SELECT id FROM chirps
+    WHERE status = 'scheduled' AND publish_at <= NOW()
+    ORDER BY publish_at ASC
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, body, user_id, status, publish_at, published_at, visibility, hidden_at, edited_at
+`
+
+func (q *Queries) PublishDueChirps(ctx context.Context, limit int32) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, publishDueChirps, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.PublishedAt,
+			&i.Visibility,
+			&i.HiddenAt,
+			&i.EditedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const publishScheduledChirp = `-- name: PublishScheduledChirp :one
+UPDATE chirps
+SET updated_at = NOW(), status = 'published', published_at = NOW()
+WHERE id = $1 AND status = 'scheduled' AND publish_at <= NOW()
+RETURNING id, created_at, updated_at, body, user_id, status, publish_at, published_at, visibility, hidden_at, edited_at
+`
+
+// Publishes the chirp only if it is still scheduled and due, so a job for a
+// chirp that was since rescheduled, made a draft or deleted does nothing.
+func (q *Queries) PublishScheduledChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, publishScheduledChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+		&i.PublishedAt,
+		&i.Visibility,
+		&i.HiddenAt,
+		&i.EditedAt,
+	)
+	return i, err
+}
+
+const updateUnpublishedChirp = `-- name: UpdateUnpublishedChirp :one
+UPDATE chirps
+SET updated_at = NOW(), body = $1, visibility = $2, status = $3, publish_at = $4
+WHERE id = $5 AND user_id = $6 AND status <> 'published'
+RETURNING id, created_at, updated_at, body, user_id, status, publish_at, published_at, visibility, hidden_at, edited_at
+`
+
+type UpdateUnpublishedChirpParams struct {
+	Body       string
+	Visibility string
+	Status     string
+	PublishAt  sql.NullTime
+	ID         uuid.UUID
+	UserID     uuid.UUID
+}
+
+func (q *Queries) UpdateUnpublishedChirp(ctx context.Context, arg UpdateUnpublishedChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateUnpublishedChirp,
+		arg.Body,
+		arg.Visibility,
+		arg.Status,
+		arg.PublishAt,
+		arg.ID,
+		arg.UserID,
+	)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+		&i.PublishedAt,
+		&i.Visibility,
+		&i.HiddenAt,
+		&i.EditedAt,
+	)
+	return i, err
+}
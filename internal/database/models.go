@@ -6,17 +6,157 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type Appeal struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Message    string
+	Status     string
+	Response   string
+	ReviewedBy uuid.NullUUID
+	ReviewedAt sql.NullTime
+}
+
 type Chirp struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Body        string
+	UserID      uuid.UUID
+	Status      string
+	PublishAt   sql.NullTime
+	PublishedAt sql.NullTime
+	Visibility  string
+	HiddenAt    sql.NullTime
+	EditedAt    sql.NullTime
+}
+
+type ChirpFlag struct {
+	ChirpID   uuid.UUID
+	RuleID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type FilterRule struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
-	Body      string
+	Pattern   string
+	Action    string
+	Enabled   bool
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+}
+
+type Job struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Queue       string
+	Kind        string
+	Payload     json.RawMessage
+	Status      string
+	Attempts    int32
+	MaxAttempts int32
+	RunAt       time.Time
+	LockedUntil sql.NullTime
+	LastError   string
+	UniqueKey   sql.NullString
+	FinishedAt  sql.NullTime
+}
+
+type Medium struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UserID       uuid.UUID
+	ChirpID      uuid.NullUUID
+	Position     int32
+	ContentType  string
+	Width        int32
+	Height       int32
+	SizeBytes    int64
+	StorageKey   string
+	ThumbnailKey string
+}
+
+type ModerationAction struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	CaseID        uuid.NullUUID
+	ModeratorID   uuid.NullUUID
+	Action        string
+	Note          string
+	SubjectUserID uuid.NullUUID
+}
+
+type ModerationCase struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	ChirpID    uuid.UUID
+	Status     string
+	ClaimedBy  uuid.NullUUID
+	ClaimedAt  sql.NullTime
+	Resolution sql.NullString
+	ResolvedBy uuid.NullUUID
+	ResolvedAt sql.NullTime
+}
+
+type Plan struct {
+	ID                string
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	Name              string
+	MaxChirpLength    int32
+	EditWindowSeconds int32
+	ChirpsPerHour     int32
+	MaxMediaPerChirp  int32
+	CanSchedule       bool
+	Badge             string
+}
+
+type Poll struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	ChirpID        uuid.UUID
+	ClosesAt       time.Time
+	MultipleChoice bool
+	HideResults    bool
+}
+
+type PollBallot struct {
+	PollID    uuid.UUID
 	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type PollOption struct {
+	ID       uuid.UUID
+	PollID   uuid.UUID
+	Position int32
+	Text     string
+}
+
+type PollVote struct {
+	PollID   uuid.UUID
+	UserID   uuid.UUID
+	OptionID uuid.UUID
+}
+
+type RateLimit struct {
+	Key string
+	Tat time.Time
 }
 
 type RefreshToken struct {
@@ -28,11 +168,101 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime
 }
 
+type Report struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	CaseID     uuid.UUID
+	ChirpID    uuid.UUID
+	ReporterID uuid.UUID
+	Reason     string
+	Details    string
+}
+
+type Subscription struct {
+	ID                 uuid.UUID
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+	UserID             uuid.UUID
+	PlanID             string
+	Status             string
+	CurrentPeriodStart time.Time
+	CurrentPeriodEnd   time.Time
+	CanceledAt         sql.NullTime
+}
+
 type User struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Email            string
+	HashedPassword   string
+	Handle           string
+	DisplayName      string
+	Bio              string
+	AvatarUrl        string
+	Role             string
+	SuspendedUntil   sql.NullTime
+	SuspensionReason string
+	BannedAt         sql.NullTime
+	ShadowLimited    bool
+}
+
+type UserPlan struct {
+	UserID            uuid.UUID
+	ID                string
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	Name              string
+	MaxChirpLength    int32
+	EditWindowSeconds int32
+	ChirpsPerHour     int32
+	MaxMediaPerChirp  int32
+	CanSchedule       bool
+	Badge             string
+}
+
+type WebhookDelivery struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	EndpointID    uuid.UUID
+	EventID       uuid.UUID
+	EventType     string
+	Payload       json.RawMessage
+	Status        string
+	Attempts      int32
+	NextAttemptAt time.Time
+	LastAttemptAt sql.NullTime
+	DeliveredAt   sql.NullTime
+}
+
+type WebhookDeliveryAttempt struct {
+	ID          uuid.UUID
+	DeliveryID  uuid.UUID
+	AttemptedAt time.Time
+	StatusCode  sql.NullInt32
+	Error       string
+	DurationMs  int32
+}
+
+type WebhookEndpoint struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	OwnerID    uuid.UUID
+	Url        string
+	EventTypes []string
+	Secret     string
+	AllUsers   bool
+}
+
+type WebhookEvent struct {
+	ID         uuid.UUID
+	ReceivedAt time.Time
+	Provider   string
+	EventType  string
+	UserID     uuid.NullUUID
+	Payload    json.RawMessage
+	Outcome    string
+	EventID    sql.NullString
 }
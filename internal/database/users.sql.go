@@ -7,29 +7,33 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, handle, display_name, bio, avatar_url, role, suspended_until, suspension_reason, banned_at, shadow_limited
 `
 
 type CreateUserParams struct {
 	Email          string
 	HashedPassword string
+	Handle         string
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
-	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword)
+	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword, arg.Handle)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -37,7 +41,15 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.BannedAt,
+		&i.ShadowLimited,
 	)
 	return i, err
 }
@@ -52,7 +64,7 @@ func (q *Queries) DeleteAllUsers(ctx context.Context) error {
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users
+SELECT id, created_at, updated_at, email, hashed_password, handle, display_name, bio, avatar_url, role, suspended_until, suspension_reason, banned_at, shadow_limited FROM users
 WHERE email = $1
 `
 
@@ -65,7 +77,130 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.BannedAt,
+		&i.ShadowLimited,
+	)
+	return i, err
+}
+
+const getUserByHandle = `-- name: GetUserByHandle :one
+SELECT id, created_at, updated_at, email, hashed_password, handle, display_name, bio, avatar_url, role, suspended_until, suspension_reason, banned_at, shadow_limited FROM users
+WHERE LOWER(handle) = LOWER($1)
+`
+
+func (q *Queries) GetUserByHandle(ctx context.Context, handle string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByHandle, handle)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.BannedAt,
+		&i.ShadowLimited,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, handle, display_name, bio, avatar_url, role, suspended_until, suspension_reason, banned_at, shadow_limited FROM users
+WHERE id = $1
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.BannedAt,
+		&i.ShadowLimited,
+	)
+	return i, err
+}
+
+const getUserProfileByHandle = `-- name: GetUserProfileByHandle :one
+SELECT users.id, users.created_at, users.updated_at, users.email, users.hashed_password, users.handle, users.display_name, users.bio, users.avatar_url, users.role, users.suspended_until, users.suspension_reason, users.banned_at, users.shadow_limited,
+    (SELECT COUNT(*) FROM follows WHERE follows.followee_id = users.id) AS follower_count,
+    (SELECT COUNT(*) FROM follows WHERE follows.follower_id = users.id) AS following_count,
+    (SELECT COUNT(*) FROM chirps WHERE chirps.user_id = users.id AND chirps.status = 'published' AND chirps.visibility = 'public' AND chirps.hidden_at IS NULL) AS chirp_count,
+    user_plans.id AS plan_id,
+    user_plans.badge
+FROM users
+JOIN user_plans ON user_plans.user_id = users.id
+WHERE LOWER(users.handle) = LOWER($1)
+`
+
+type GetUserProfileByHandleRow struct {
+	ID               uuid.UUID
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	Email            string
+	HashedPassword   string
+	Handle           string
+	DisplayName      string
+	Bio              string
+	AvatarUrl        string
+	Role             string
+	SuspendedUntil   sql.NullTime
+	SuspensionReason string
+	BannedAt         sql.NullTime
+	ShadowLimited    bool
+	FollowerCount    int64
+	FollowingCount   int64
+	ChirpCount       int64
+	PlanID           string
+	Badge            string
+}
+
+func (q *Queries) GetUserProfileByHandle(ctx context.Context, handle string) (GetUserProfileByHandleRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserProfileByHandle, handle)
+	var i GetUserProfileByHandleRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.BannedAt,
+		&i.ShadowLimited,
+		&i.FollowerCount,
+		&i.FollowingCount,
+		&i.ChirpCount,
+		&i.PlanID,
+		&i.Badge,
 	)
 	return i, err
 }
@@ -74,7 +209,7 @@ const updateUser = `-- name: UpdateUser :one
 UPDATE users
 SET updated_at = NOW(), email = $1, hashed_password = $2
 WHERE id = $3
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, handle, display_name, bio, avatar_url, role, suspended_until, suspension_reason, banned_at, shadow_limited
 `
 
 type UpdateUserParams struct {
@@ -92,18 +227,58 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.UpdatedAt,
 		&i.Email,
 		&i.HashedPassword,
-		&i.IsChirpyRed,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.BannedAt,
+		&i.ShadowLimited,
 	)
 	return i, err
 }
 
-const upgradeUserChirpyRed = `-- name: UpgradeUserChirpyRed :exec
+const updateUserProfile = `-- name: UpdateUserProfile :one
 UPDATE users
-SET is_chirpy_red = true
-WHERE id = $1
+SET updated_at = NOW(), handle = $1, display_name = $2, bio = $3, avatar_url = $4
+WHERE id = $5
+RETURNING id, created_at, updated_at, email, hashed_password, handle, display_name, bio, avatar_url, role, suspended_until, suspension_reason, banned_at, shadow_limited
 `
 
-func (q *Queries) UpgradeUserChirpyRed(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, upgradeUserChirpyRed, id)
-	return err
+type UpdateUserProfileParams struct {
+	Handle      string
+	DisplayName string
+	Bio         string
+	AvatarUrl   string
+	ID          uuid.UUID
+}
+
+func (q *Queries) UpdateUserProfile(ctx context.Context, arg UpdateUserProfileParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, updateUserProfile,
+		arg.Handle,
+		arg.DisplayName,
+		arg.Bio,
+		arg.AvatarUrl,
+		arg.ID,
+	)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.Handle,
+		&i.DisplayName,
+		&i.Bio,
+		&i.AvatarUrl,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.BannedAt,
+		&i.ShadowLimited,
+	)
+	return i, err
 }
@@ -0,0 +1,92 @@
+package filter
+
+// automaton is an Aho-Corasick automaton over runes. It finds every
+// occurrence of every pattern in one pass over the input.
+type automaton struct {
+	next [](map[rune]int)
+	fail []int
+	// out lists the patterns that end at each state, by index
+	out [][]int
+	lens []int
+}
+
+func newAutomaton(patterns [][]rune) *automaton {
+
+	a := &automaton{
+		next: []map[rune]int{{}},
+		fail: []int{0},
+		out:  [][]int{nil},
+		lens: make([]int, len(patterns)),
+	}
+
+	for i, p := range patterns {
+		state := 0
+		for _, r := range p {
+			n, ok := a.next[state][r]
+			if !ok {
+				n = len(a.next)
+				a.next = append(a.next, map[rune]int{})
+				a.fail = append(a.fail, 0)
+				a.out = append(a.out, nil)
+				a.next[state][r] = n
+			}
+			state = n
+		}
+		a.out[state] = append(a.out[state], i)
+		a.lens[i] = len(p)
+	}
+
+	// breadth first, so fail links always point at finished states
+	queue := []int{}
+	for _, n := range a.next[0] {
+		queue = append(queue, n)
+	}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for r, n := range a.next[state] {
+			queue = append(queue, n)
+			f := a.fail[state]
+			for f != 0 {
+				if _, ok := a.next[f][r]; ok {
+					break
+				}
+				f = a.fail[f]
+			}
+			if target, ok := a.next[f][r]; ok && target != n {
+				a.fail[n] = target
+			}
+			a.out[n] = append(a.out[n], a.out[a.fail[n]]...)
+		}
+	}
+
+	return a
+}
+
+type hit struct {
+	pattern    int
+	start, end int
+}
+
+// find returns every pattern occurrence in text as rune offsets.
+func (a *automaton) find(text []rune) []hit {
+
+	var hits []hit
+	state := 0
+	for i, r := range text {
+		for state != 0 {
+			if _, ok := a.next[state][r]; ok {
+				break
+			}
+			state = a.fail[state]
+		}
+		if n, ok := a.next[state][r]; ok {
+			state = n
+		}
+		for _, p := range a.out[state] {
+			hits = append(hits, hit{pattern: p, start: i + 1 - a.lens[p], end: i + 1})
+		}
+	}
+
+	return hits
+}
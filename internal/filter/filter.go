@@ -0,0 +1,166 @@
+// Package filter implements the chirp content filter.
+//
+// Rules are plain words or phrases. Both rules and chirps are folded (see
+// fold) before matching, so case, accents, punctuation, full-width forms
+// and common leetspeak don't get around a rule. Rules only match whole
+// words, which keeps "kerfuffle" from firing inside "kerfuffled", and
+// punctuation counts as a word boundary.
+package filter
+
+import (
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+)
+
+type Action string
+
+const (
+	// ActionMask replaces the matched text with asterisks.
+	ActionMask Action = "mask"
+	// ActionReject refuses the chirp outright.
+	ActionReject Action = "reject"
+	// ActionFlag lets the chirp through but queues it for review.
+	ActionFlag Action = "flag"
+)
+
+func (a Action) Valid() bool {
+	return a == ActionMask || a == ActionReject || a == ActionFlag
+}
+
+type Rule struct {
+	ID      uuid.UUID
+	Pattern string
+	Action  Action
+}
+
+type Match struct {
+	Rule Rule
+	// Start and End are byte offsets into the original text.
+	Start, End int
+}
+
+type Result struct {
+	// Text is the input with masked matches replaced.
+	Text     string
+	Rejected bool
+	Matches  []Match
+}
+
+// Flagged returns the distinct flag rules that matched.
+func (r Result) Flagged() []Rule {
+	var rules []Rule
+	seen := map[uuid.UUID]bool{}
+	for _, m := range r.Matches {
+		if m.Rule.Action == ActionFlag && !seen[m.Rule.ID] {
+			seen[m.Rule.ID] = true
+			rules = append(rules, m.Rule)
+		}
+	}
+	return rules
+}
+
+// Matcher is a compiled, immutable set of rules.
+type Matcher struct {
+	rules []Rule
+	ac    *automaton
+}
+
+func Compile(rules []Rule) *Matcher {
+
+	m := &Matcher{}
+	patterns := make([][]rune, 0, len(rules))
+	for _, rule := range rules {
+		p, _ := fold(rule.Pattern)
+		if len(p) == 0 || !rule.Action.Valid() {
+			continue
+		}
+		m.rules = append(m.rules, rule)
+		patterns = append(patterns, p)
+	}
+	m.ac = newAutomaton(patterns)
+
+	return m
+}
+
+func (m *Matcher) Apply(text string) Result {
+
+	folded, spans := fold(text)
+	res := Result{Text: text}
+
+	for _, h := range m.ac.find(folded) {
+		// whole words only
+		if h.start > 0 && folded[h.start-1] != ' ' {
+			continue
+		}
+		if h.end < len(folded) && folded[h.end] != ' ' {
+			continue
+		}
+		rule := m.rules[h.pattern]
+		res.Matches = append(res.Matches, Match{
+			Rule:  rule,
+			Start: spans[h.start].start,
+			End:   spans[h.end-1].end,
+		})
+		if rule.Action == ActionReject {
+			res.Rejected = true
+		}
+	}
+
+	res.Text = mask(text, res.Matches)
+	return res
+}
+
+func mask(text string, matches []Match) string {
+
+	var ranges []Match
+	for _, m := range matches {
+		if m.Rule.Action == ActionMask {
+			ranges = append(ranges, m)
+		}
+	}
+	if len(ranges) == 0 {
+		return text
+	}
+
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].Start < ranges[j].Start })
+
+	var b strings.Builder
+	pos := 0
+	for _, m := range ranges {
+		if m.End <= pos {
+			continue
+		}
+		if m.Start >= pos {
+			b.WriteString(text[pos:m.Start])
+			b.WriteString("****")
+		}
+		// overlapping matches extend the masked range already written
+		pos = m.End
+	}
+	b.WriteString(text[pos:])
+
+	return b.String()
+}
+
+// Filter holds the current Matcher and lets it be swapped while chirps are
+// being checked.
+type Filter struct {
+	current atomic.Pointer[Matcher]
+}
+
+func New(rules []Rule) *Filter {
+	f := &Filter{}
+	f.Load(rules)
+	return f
+}
+
+func (f *Filter) Load(rules []Rule) {
+	f.current.Store(Compile(rules))
+}
+
+func (f *Filter) Apply(text string) Result {
+	return f.current.Load().Apply(text)
+}
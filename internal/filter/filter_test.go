@@ -0,0 +1,158 @@
+package filter
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func testRules() []Rule {
+	return []Rule{
+		{ID: uuid.New(), Pattern: "kerfuffle", Action: ActionMask},
+		{ID: uuid.New(), Pattern: "sharbert", Action: ActionMask},
+		{ID: uuid.New(), Pattern: "fornax", Action: ActionMask},
+		{ID: uuid.New(), Pattern: "buy followers", Action: ActionReject},
+		{ID: uuid.New(), Pattern: "crypto giveaway", Action: ActionFlag},
+	}
+}
+
+func TestApply(t *testing.T) {
+	f := New(testRules())
+
+	tests := []struct {
+		name         string
+		text         string
+		wantText     string
+		wantRejected bool
+		wantFlags    int
+	}{
+		{
+			name:     "Clean text",
+			text:     "I had something interesting for breakfast",
+			wantText: "I had something interesting for breakfast",
+		},
+		{
+			name:     "Plain word",
+			text:     "This is a kerfuffle opinion",
+			wantText: "This is a **** opinion",
+		},
+		{
+			name:     "Trailing punctuation",
+			text:     "What a kerfuffle!",
+			wantText: "What a ****!",
+		},
+		{
+			name:     "Upper case",
+			text:     "KERFUFFLE at the start",
+			wantText: "**** at the start",
+		},
+		{
+			name:     "Leetspeak",
+			text:     "such a k3rfuffl3 today",
+			wantText: "such a **** today",
+		},
+		{
+			name:     "Dotted letters",
+			text:     "s.h.a.r.b.e.r.t again",
+			wantText: "**** again",
+		},
+		{
+			name:     "Joined by punctuation",
+			text:     "hello,kerfuffle and Kerfuffle/fornax",
+			wantText: "hello,**** and ****/****",
+		},
+		{
+			name:     "Between dashes and brackets",
+			text:     "(sharbert)--fornax",
+			wantText: "(****)--****",
+		},
+		{
+			name:     "Zero-width space inside",
+			text:     "kerf\u200buffle",
+			wantText: "****",
+		},
+		{
+			name:     "Accents",
+			text:     "fórnáx",
+			wantText: "****",
+		},
+		{
+			name:     "Decomposed accents",
+			text:     "forna\u0301x here",
+			wantText: "**** here",
+		},
+		{
+			name:     "Full width",
+			text:     "ｋｅｒｆｕｆｆｌｅ",
+			wantText: "****",
+		},
+		{
+			name:     "Punctuation inside a longer word",
+			text:     "kerfuffle's and fornax.com",
+			wantText: "****'s and ****.com",
+		},
+		{
+			name:     "Part of a longer word",
+			text:     "kerfuffled and fornaxes",
+			wantText: "kerfuffled and fornaxes",
+		},
+		{
+			name:         "Reject phrase across whitespace",
+			text:         "Buy   FOLLOWERS now",
+			wantText:     "Buy   FOLLOWERS now",
+			wantRejected: true,
+		},
+		{
+			name:         "Reject phrase across punctuation",
+			text:         "buy.followers",
+			wantText:     "buy.followers",
+			wantRejected: true,
+		},
+		{
+			name:      "Flag phrase",
+			text:      "huge crypto giveaway, crypto giveaway!",
+			wantText:  "huge crypto giveaway, crypto giveaway!",
+			wantFlags: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := f.Apply(tt.text)
+			if res.Text != tt.wantText {
+				t.Errorf("Apply(%q).Text = %q, want %q", tt.text, res.Text, tt.wantText)
+			}
+			if res.Rejected != tt.wantRejected {
+				t.Errorf("Apply(%q).Rejected = %v, want %v", tt.text, res.Rejected, tt.wantRejected)
+			}
+			if got := len(res.Flagged()); got != tt.wantFlags {
+				t.Errorf("len(Apply(%q).Flagged()) = %d, want %d", tt.text, got, tt.wantFlags)
+			}
+		})
+	}
+}
+
+func TestLoadSwapsRules(t *testing.T) {
+	f := New(nil)
+
+	if got := f.Apply("fornax").Text; got != "fornax" {
+		t.Fatalf("Apply() with no rules = %q, want unchanged", got)
+	}
+
+	f.Load(testRules())
+
+	if got := f.Apply("fornax").Text; got != "****" {
+		t.Errorf("Apply() after Load() = %q, want %q", got, "****")
+	}
+}
+
+func TestOverlappingMatches(t *testing.T) {
+	f := New([]Rule{
+		{ID: uuid.New(), Pattern: "bad word", Action: ActionMask},
+		{ID: uuid.New(), Pattern: "word salad", Action: ActionMask},
+	})
+
+	if got := f.Apply("a bad word salad").Text; got != "a ****" {
+		t.Errorf("Apply() = %q, want %q", got, "a ****")
+	}
+}
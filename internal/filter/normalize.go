@@ -0,0 +1,145 @@
+package filter
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// leet maps look-alike digits to the letters they usually stand in for.
+var leet = map[rune]rune{
+	'0': 'o',
+	'1': 'i',
+	'3': 'e',
+	'4': 'a',
+	'5': 's',
+	'7': 't',
+}
+
+// leetSymbols are only folded inside words so that "$" or "@" on their
+// own, or a trailing "!", don't turn into letters.
+var leetSymbols = map[rune]rune{
+	'@': 'a',
+	'$': 's',
+}
+
+// span is the byte range of the original text a folded rune came from.
+type span struct {
+	start, end int
+}
+
+// fold reduces text to the form rules are matched against: compatibility
+// decomposed, accents dropped, lower case and leetspeak undone. Runs of
+// whitespace and punctuation become one space, so "hello,kerfuffle" is two
+// words, except that punctuation between single letters is dropped so
+// "s.p.a.m" still reads as one. For each folded rune it also returns where
+// in text it came from.
+func fold(text string) ([]rune, []span) {
+
+	out := make([]rune, 0, len(text))
+	spans := make([]span, 0, len(text))
+	// punct marks separators that came only from punctuation
+	punct := make([]bool, 0, len(text))
+
+	emit := func(r rune, s span) {
+		out = append(out, r)
+		spans = append(spans, s)
+		punct = append(punct, false)
+	}
+	separate := func(s span, from_punct bool) {
+		if len(out) == 0 {
+			return
+		}
+		if out[len(out)-1] == ' ' {
+			// a gap mixing space and punctuation is an ordinary gap
+			punct[len(punct)-1] = punct[len(punct)-1] && from_punct
+			return
+		}
+		emit(' ', s)
+		punct[len(punct)-1] = from_punct
+	}
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		s := span{i, i + size}
+		i += size
+
+		switch {
+		case unicode.IsSpace(r):
+			separate(s, false)
+			continue
+		case unicode.IsMark(r), unicode.Is(unicode.Cf, r):
+			// a combining mark belongs to whatever it decorates, and
+			// invisible formatting characters like zero-width spaces
+			// mustn't split a word
+			if len(spans) > 0 {
+				spans[len(spans)-1].end = s.end
+			}
+			continue
+		}
+
+		if l, ok := leetSymbols[r]; ok {
+			next, _ := utf8.DecodeRuneInString(text[i:])
+			if len(out) > 0 && out[len(out)-1] != ' ' || unicode.IsLetter(next) || unicode.IsDigit(next) {
+				emit(l, s)
+			} else {
+				separate(s, true)
+			}
+			continue
+		}
+
+		for _, d := range norm.NFKD.String(string(r)) {
+			if unicode.IsMark(d) {
+				continue
+			}
+			d = unicode.ToLower(d)
+			if l, ok := leet[d]; ok {
+				d = l
+			}
+			switch {
+			case unicode.IsLetter(d) || unicode.IsDigit(d):
+				emit(d, s)
+			case unicode.IsSpace(d):
+				separate(s, false)
+			default:
+				separate(s, true)
+			}
+		}
+	}
+
+	// drop a trailing separator so patterns fold to a clean form
+	if len(out) > 0 && out[len(out)-1] == ' ' {
+		out = out[:len(out)-1]
+		spans = spans[:len(spans)-1]
+		punct = punct[:len(punct)-1]
+	}
+
+	return joinSpelledOut(out, spans, punct)
+}
+
+// joinSpelledOut drops punctuation separators that sit between two single
+// characters, turning "s p a m" folded from "s.p.a.m" back into "spam".
+func joinSpelledOut(out []rune, spans []span, punct []bool) ([]rune, []span) {
+
+	single := func(i int) bool {
+		return i >= 0 && i < len(out) && out[i] != ' ' &&
+			(i == 0 || out[i-1] == ' ') && (i == len(out)-1 || out[i+1] == ' ')
+	}
+
+	drop := make([]bool, len(out))
+	for i := range out {
+		drop[i] = out[i] == ' ' && punct[i] && single(i-1) && single(i+1)
+	}
+
+	j := 0
+	for i := range out {
+		if drop[i] {
+			continue
+		}
+		out[j] = out[i]
+		spans[j] = spans[i]
+		j++
+	}
+	return out[:j], spans[:j]
+}
@@ -0,0 +1,166 @@
+// Package health runs the checks behind Chirpy's readiness endpoint.
+//
+// Subsystems register a Checker under a name; Run calls every check at
+// once, each under its own timeout, and reports them one by one so an
+// operator can see which dependency is down.
+package health
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Checker reports whether a dependency is usable. It should give up once
+// ctx is done.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc lets a plain function be a Checker.
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// Report is the outcome of every check. Status is ok only if every check
+// passed.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// OK reports whether every check passed.
+func (r Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+// Public is the report without error messages, which can name hosts,
+// ports and driver details, for showing to anyone who asks.
+func (r Report) Public() Report {
+
+	public := Report{Status: r.Status, Checks: map[string]Result{}}
+	for name, res := range r.Checks {
+		res.Error = ""
+		public.Checks[name] = res
+	}
+	return public
+}
+
+// Registry holds the registered checks. The zero value is ready to use and
+// runs each check with DefaultTimeout.
+type Registry struct {
+	// Timeout bounds each check.
+	Timeout time.Duration
+
+	mu     sync.Mutex
+	checks map[string]Checker
+}
+
+const DefaultTimeout = 2 * time.Second
+
+// Register adds a check, replacing any registered under the same name.
+func (r *Registry) Register(name string, c Checker) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.checks == nil {
+		r.checks = map[string]Checker{}
+	}
+	r.checks[name] = c
+}
+
+// Names lists the registered checks in order.
+func (r *Registry) Names() []string {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := []string{}
+	for name := range r.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run calls every check concurrently and waits for all of them.
+func (r *Registry) Run(ctx context.Context) Report {
+
+	r.mu.Lock()
+	checks := map[string]Checker{}
+	for name, c := range r.checks {
+		checks[name] = c
+	}
+	r.mu.Unlock()
+
+	timeout := r.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	report := Report{Status: StatusOK, Checks: map[string]Result{}}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := run(ctx, c, timeout)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = res
+			if res.Status != StatusOK {
+				report.Status = StatusFail
+			}
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+func run(ctx context.Context, c Checker, timeout time.Duration) Result {
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Check(ctx)
+	}()
+
+	// don't let a check that ignores ctx hold up the whole report
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	res := Result{Status: StatusOK, DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		res.Status = StatusFail
+		res.Error = err.Error()
+		if errors.Is(err, context.DeadlineExceeded) {
+			res.Error = "timed out after " + timeout.String()
+		}
+	}
+	return res
+}
@@ -0,0 +1,76 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+
+	r := &Registry{Timeout: 20 * time.Millisecond}
+	r.Register("db", CheckerFunc(func(ctx context.Context) error { return nil }))
+	r.Register("blobs", CheckerFunc(func(ctx context.Context) error { return errors.New("bucket missing") }))
+	// ignores ctx entirely
+	r.Register("stuck", CheckerFunc(func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}))
+
+	start := time.Now()
+	report := r.Run(context.Background())
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Run() took %v, want it bounded by the timeout", elapsed)
+	}
+
+	if report.OK() {
+		t.Error("report is ok, want fail")
+	}
+
+	tests := []struct {
+		name   string
+		status string
+		err    string
+	}{
+		{"db", StatusOK, ""},
+		{"blobs", StatusFail, "bucket missing"},
+		{"stuck", StatusFail, "timed out after 20ms"},
+	}
+	for _, tc := range tests {
+		got := report.Checks[tc.name]
+		if got.Status != tc.status || got.Error != tc.err {
+			t.Errorf("%s = %+v, want status %q and error %q", tc.name, got, tc.status, tc.err)
+		}
+	}
+}
+
+func TestPublic(t *testing.T) {
+
+	report := Report{
+		Status: StatusFail,
+		Checks: map[string]Result{
+			"database": {Status: StatusFail, Error: "dial tcp 10.0.0.5:5432: connection refused", DurationMs: 3},
+			"blobs":    {Status: StatusOK, DurationMs: 1},
+		},
+	}
+
+	public := report.Public()
+	if public.Status != StatusFail || len(public.Checks) != 2 {
+		t.Fatalf("Public() = %+v", public)
+	}
+	if got := public.Checks["database"]; got.Error != "" || got.Status != StatusFail || got.DurationMs != 3 {
+		t.Errorf("database = %+v, want the status and timing without the error", got)
+	}
+	if report.Checks["database"].Error == "" {
+		t.Error("Public() changed the original report")
+	}
+}
+
+func TestRunEmpty(t *testing.T) {
+
+	report := (&Registry{}).Run(context.Background())
+	if !report.OK() || len(report.Checks) != 0 {
+		t.Errorf("Run() with no checks = %+v, want ok and empty", report)
+	}
+}
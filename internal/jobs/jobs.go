@@ -0,0 +1,348 @@
+// Package jobs runs background work stored in a durable queue.
+//
+// Jobs are enqueued as rows, normally in the same transaction as the change
+// that needs them, and picked up by a Runner on any replica. A job that
+// fails is retried with backoff until it runs out of attempts. Periodic jobs
+// are enqueued with a unique key per time slot, so only one replica's copy
+// of each run makes it into the queue.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/frozendolphin/Chirpy/internal/logging"
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultQueue       = "default"
+	DefaultMaxAttempts = 5
+)
+
+// Args is the payload of a job. Kind names the handler that runs it and
+// must be stable, since it is stored with every job.
+type Args interface {
+	Kind() string
+}
+
+// Options control how a job is enqueued. The zero value runs the job now on
+// DefaultQueue.
+type Options struct {
+	Queue       string
+	RunAt       time.Time
+	MaxAttempts int
+	// UniqueKey, when set, makes enqueueing a second job with the same key a
+	// no-op.
+	UniqueKey string
+}
+
+// NewJob is a job ready to be inserted by a Store.
+type NewJob struct {
+	Queue       string
+	Kind        string
+	Payload     json.RawMessage
+	RunAt       time.Time
+	MaxAttempts int
+	UniqueKey   string
+}
+
+// Prepare encodes args and fills in defaults for the options left empty.
+func Prepare(args Args, opts Options) (NewJob, error) {
+
+	payload, err := json.Marshal(args)
+	if err != nil {
+		return NewJob{}, fmt.Errorf("couldn't encode %s job: %w", args.Kind(), err)
+	}
+
+	job := NewJob{
+		Queue:       opts.Queue,
+		Kind:        args.Kind(),
+		Payload:     payload,
+		RunAt:       opts.RunAt,
+		MaxAttempts: opts.MaxAttempts,
+		UniqueKey:   opts.UniqueKey,
+	}
+	if job.Queue == "" {
+		job.Queue = DefaultQueue
+	}
+	if job.RunAt.IsZero() {
+		job.RunAt = time.Now().UTC()
+	}
+	if job.MaxAttempts < 1 {
+		job.MaxAttempts = DefaultMaxAttempts
+	}
+	return job, nil
+}
+
+// Job is a claimed job. Attempt counts from 1.
+type Job struct {
+	ID          uuid.UUID
+	Queue       string
+	Kind        string
+	Payload     json.RawMessage
+	Attempt     int
+	MaxAttempts int
+}
+
+// Store is the durable queue a Runner works from.
+type Store interface {
+	// Insert adds a job, reporting false if its unique key was taken.
+	Insert(ctx context.Context, job NewJob) (bool, error)
+	// Claim takes up to limit due jobs from queue and leases them until
+	// lease has passed, after which they are due again.
+	Claim(ctx context.Context, queue string, limit int, lease time.Duration) ([]Job, error)
+	Complete(ctx context.Context, id uuid.UUID) error
+	Retry(ctx context.Context, id uuid.UUID, at time.Time, reason string) error
+	Fail(ctx context.Context, id uuid.UUID, reason string) error
+}
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so the job fails right away instead of being retried.
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+// DefaultBackoff waits 10 seconds after the first failure and doubles the
+// wait after each one, up to an hour.
+func DefaultBackoff(attempt int) time.Duration {
+
+	d := 10 * time.Second
+	for i := 1; i < attempt && d < time.Hour; i++ {
+		d *= 2
+	}
+	return min(d, time.Hour)
+}
+
+type periodic struct {
+	interval time.Duration
+	args     Args
+	opts     Options
+}
+
+// Runner claims jobs from a Store and runs their handlers. Configure it
+// with Register, Queue and Periodic before calling Start.
+type Runner struct {
+	// PollInterval is how often idle queues look for due jobs.
+	PollInterval time.Duration
+	// Lease is how long a claimed job may run before another worker can
+	// take it over. Handlers should finish well within it.
+	Lease   time.Duration
+	Backoff func(attempt int) time.Duration
+
+	store    Store
+	handlers map[string]func(context.Context, Job) error
+	queues   map[string]int
+	periodic []periodic
+
+	pollCtx     context.Context
+	stopPolling context.CancelFunc
+	workCtx     context.Context
+	cancelWork  context.CancelFunc
+	loops       sync.WaitGroup
+	running     sync.WaitGroup
+}
+
+func NewRunner(store Store) *Runner {
+	return &Runner{
+		PollInterval: time.Second,
+		Lease:        5 * time.Minute,
+		Backoff:      DefaultBackoff,
+		store:        store,
+		handlers:     map[string]func(context.Context, Job) error{},
+		queues:       map[string]int{DefaultQueue: 10},
+	}
+}
+
+// Register sets the handler for jobs of kind T. A payload that can't be
+// decoded into T fails the job without retrying.
+func Register[T Args](r *Runner, handler func(ctx context.Context, args T) error) {
+
+	var zero T
+	r.handlers[zero.Kind()] = func(ctx context.Context, job Job) error {
+		var args T
+		err := json.Unmarshal(job.Payload, &args)
+		if err != nil {
+			return Permanent(fmt.Errorf("couldn't decode payload: %w", err))
+		}
+		return handler(ctx, args)
+	}
+}
+
+// Queue sets how many jobs from the named queue may run at once.
+func (r *Runner) Queue(name string, concurrency int) {
+	r.queues[name] = max(concurrency, 1)
+}
+
+// Periodic enqueues args once every interval, counted from the Unix epoch.
+// Every replica may call this; the slot's unique key keeps it to one job.
+func (r *Runner) Periodic(interval time.Duration, args Args, opts Options) {
+	r.periodic = append(r.periodic, periodic{interval: interval, args: args, opts: opts})
+}
+
+// Start begins polling. Jobs keep running when ctx is canceled; use
+// Shutdown to let them finish.
+func (r *Runner) Start(ctx context.Context) {
+
+	r.pollCtx, r.stopPolling = context.WithCancel(ctx)
+	r.workCtx, r.cancelWork = context.WithCancel(context.WithoutCancel(ctx))
+
+	for queue, concurrency := range r.queues {
+		r.loops.Add(1)
+		go r.work(queue, concurrency)
+	}
+	for _, p := range r.periodic {
+		r.loops.Add(1)
+		go r.schedule(p)
+	}
+}
+
+// Shutdown stops claiming jobs and waits for running ones to finish. If
+// ctx ends first, running jobs have their context canceled and Shutdown
+// returns ctx's error once they return. Jobs that never record an outcome
+// are picked up again when their lease runs out.
+func (r *Runner) Shutdown(ctx context.Context) error {
+
+	r.stopPolling()
+	r.loops.Wait()
+
+	done := make(chan struct{})
+	go func() {
+		r.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		r.cancelWork()
+		return nil
+	case <-ctx.Done():
+		r.cancelWork()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (r *Runner) work(queue string, concurrency int) {
+
+	defer r.loops.Done()
+
+	ticker := time.NewTicker(r.PollInterval)
+	defer ticker.Stop()
+
+	slots := make(chan struct{}, concurrency)
+	for {
+		if r.pollCtx.Err() != nil {
+			return
+		}
+
+		free := concurrency - len(slots)
+		claimed := 0
+		if free > 0 {
+			jobs, err := r.store.Claim(r.pollCtx, queue, free, r.Lease)
+			if err != nil && r.pollCtx.Err() == nil {
+				slog.Error("couldn't claim jobs", "queue", queue, "error", err)
+			}
+			for _, job := range jobs {
+				slots <- struct{}{}
+				r.running.Add(1)
+				go func() {
+					defer r.running.Done()
+					defer func() { <-slots }()
+					r.run(job)
+				}()
+			}
+			claimed = len(jobs)
+		}
+
+		// a full claim means there may be more waiting
+		if free > 0 && claimed == free {
+			continue
+		}
+
+		select {
+		case <-r.pollCtx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Runner) run(job Job) {
+
+	logger := slog.Default().With("job_id", job.ID, "kind", job.Kind, "attempt", job.Attempt)
+	err := r.call(logger, job)
+
+	// record the outcome even if the job was cut off by a hard stop
+	ctx := context.WithoutCancel(r.workCtx)
+	switch {
+	case err == nil:
+		err = r.store.Complete(ctx, job.ID)
+	case errors.As(err, new(permanentError)) || job.Attempt >= job.MaxAttempts:
+		logger.Error("job failed for good", "error", err)
+		err = r.store.Fail(ctx, job.ID, err.Error())
+	default:
+		at := time.Now().UTC().Add(r.Backoff(job.Attempt))
+		logger.Warn("job failed, will retry", "retry_at", at, "error", err)
+		err = r.store.Retry(ctx, job.ID, at, err.Error())
+	}
+	if err != nil {
+		logger.Error("couldn't record job outcome", "error", err)
+	}
+}
+
+func (r *Runner) call(logger *slog.Logger, job Job) (err error) {
+
+	handler, ok := r.handlers[job.Kind]
+	if !ok {
+		return Permanent(fmt.Errorf("no handler for job kind %q", job.Kind))
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("job panicked: %v", p)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(r.workCtx, r.Lease)
+	defer cancel()
+	return handler(logging.WithLogger(ctx, logger), job)
+}
+
+func (r *Runner) schedule(p periodic) {
+
+	defer r.loops.Done()
+
+	ticker := time.NewTicker(min(p.interval, r.PollInterval))
+	defer ticker.Stop()
+
+	for {
+		slot := time.Unix(0, time.Now().UnixNano()/int64(p.interval)*int64(p.interval)).UTC()
+		opts := p.opts
+		opts.RunAt = slot
+		opts.UniqueKey = fmt.Sprintf("%s@%d", p.args.Kind(), slot.Unix())
+
+		job, err := Prepare(p.args, opts)
+		if err == nil {
+			_, err = r.store.Insert(r.pollCtx, job)
+		}
+		if err != nil && r.pollCtx.Err() == nil {
+			slog.Error("couldn't enqueue periodic job", "kind", p.args.Kind(), "error", err)
+		}
+
+		select {
+		case <-r.pollCtx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// memStore is an in-memory Store. It ignores leases.
+type memStore struct {
+	mu     sync.Mutex
+	jobs   map[uuid.UUID]*memJob
+	unique map[string]bool
+}
+
+type memJob struct {
+	Job
+	runAt  time.Time
+	status string
+	reason string
+}
+
+func newMemStore() *memStore {
+	return &memStore{jobs: map[uuid.UUID]*memJob{}, unique: map[string]bool{}}
+}
+
+func (s *memStore) Insert(ctx context.Context, job NewJob) (bool, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if job.UniqueKey != "" {
+		if s.unique[job.UniqueKey] {
+			return false, nil
+		}
+		s.unique[job.UniqueKey] = true
+	}
+
+	id := uuid.New()
+	s.jobs[id] = &memJob{
+		Job:    Job{ID: id, Queue: job.Queue, Kind: job.Kind, Payload: job.Payload, MaxAttempts: job.MaxAttempts},
+		runAt:  job.RunAt,
+		status: "available",
+	}
+	return true, nil
+}
+
+func (s *memStore) Claim(ctx context.Context, queue string, limit int, lease time.Duration) ([]Job, error) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	claimed := []Job{}
+	for _, job := range s.jobs {
+		if len(claimed) == limit {
+			break
+		}
+		if job.Queue == queue && job.status == "available" && !job.runAt.After(time.Now()) {
+			job.status = "running"
+			job.Attempt++
+			claimed = append(claimed, job.Job)
+		}
+	}
+	return claimed, nil
+}
+
+func (s *memStore) finish(id uuid.UUID, status, reason string, at time.Time) error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs[id].status = status
+	s.jobs[id].reason = reason
+	s.jobs[id].runAt = at
+	return nil
+}
+
+func (s *memStore) Complete(ctx context.Context, id uuid.UUID) error {
+	return s.finish(id, "completed", "", time.Time{})
+}
+
+func (s *memStore) Retry(ctx context.Context, id uuid.UUID, at time.Time, reason string) error {
+	return s.finish(id, "available", reason, at)
+}
+
+func (s *memStore) Fail(ctx context.Context, id uuid.UUID, reason string) error {
+	return s.finish(id, "failed", reason, time.Time{})
+}
+
+func (s *memStore) statuses() map[string]int {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	res := map[string]int{}
+	for _, job := range s.jobs {
+		res[job.status]++
+	}
+	return res
+}
+
+func (s *memStore) enqueue(t *testing.T, args Args, opts Options) {
+
+	t.Helper()
+	job, err := Prepare(args, opts)
+	if err != nil {
+		t.Fatalf("Prepare() error = %v", err)
+	}
+	s.Insert(context.Background(), job)
+}
+
+type flakyArgs struct {
+	Failures int `json:"failures"`
+}
+
+func (flakyArgs) Kind() string { return "flaky" }
+
+type slowArgs struct{}
+
+func (slowArgs) Kind() string { return "slow" }
+
+func newTestRunner(store Store) *Runner {
+	r := NewRunner(store)
+	r.PollInterval = 5 * time.Millisecond
+	r.Backoff = func(int) time.Duration { return 0 }
+	return r
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for jobs")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRetriesUntilSuccess(t *testing.T) {
+
+	store := newMemStore()
+	runner := newTestRunner(store)
+
+	var calls atomic.Int32
+	Register(runner, func(ctx context.Context, args flakyArgs) error {
+		if int(calls.Add(1)) <= args.Failures {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+
+	store.enqueue(t, flakyArgs{Failures: 2}, Options{})
+
+	runner.Start(context.Background())
+	waitFor(t, func() bool { return store.statuses()["completed"] == 1 })
+	runner.Shutdown(context.Background())
+
+	if calls.Load() != 3 {
+		t.Errorf("handler ran %d times, want 3", calls.Load())
+	}
+}
+
+func TestFailures(t *testing.T) {
+
+	store := newMemStore()
+	runner := newTestRunner(store)
+
+	var calls atomic.Int32
+	Register(runner, func(ctx context.Context, args flakyArgs) error {
+		calls.Add(1)
+		if args.Failures < 0 {
+			return Permanent(errors.New("bad input"))
+		}
+		return errors.New("always fails")
+	})
+
+	store.enqueue(t, flakyArgs{Failures: 100}, Options{MaxAttempts: 3})
+	store.enqueue(t, flakyArgs{Failures: -1}, Options{MaxAttempts: 3})
+	// nothing handles this kind
+	store.enqueue(t, slowArgs{}, Options{})
+
+	runner.Start(context.Background())
+	waitFor(t, func() bool { return store.statuses()["failed"] == 3 })
+	runner.Shutdown(context.Background())
+
+	// three attempts for the first job, one for the permanent failure
+	if calls.Load() != 4 {
+		t.Errorf("handler ran %d times, want 4", calls.Load())
+	}
+}
+
+func TestQueueConcurrency(t *testing.T) {
+
+	store := newMemStore()
+	runner := newTestRunner(store)
+	runner.Queue("media", 2)
+
+	var running, peak atomic.Int32
+	Register(runner, func(ctx context.Context, args slowArgs) error {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		running.Add(-1)
+		return nil
+	})
+
+	for range 6 {
+		store.enqueue(t, slowArgs{}, Options{Queue: "media"})
+	}
+
+	runner.Start(context.Background())
+	waitFor(t, func() bool { return store.statuses()["completed"] == 6 })
+	runner.Shutdown(context.Background())
+
+	if peak.Load() > 2 {
+		t.Errorf("%d jobs ran at once, want at most 2", peak.Load())
+	}
+}
+
+func TestShutdownDrains(t *testing.T) {
+
+	store := newMemStore()
+	runner := newTestRunner(store)
+
+	started := make(chan struct{})
+	Register(runner, func(ctx context.Context, args slowArgs) error {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		return ctx.Err()
+	})
+
+	store.enqueue(t, slowArgs{}, Options{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runner.Start(ctx)
+	<-started
+	cancel()
+
+	err := runner.Shutdown(context.Background())
+	if err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if got := store.statuses()["completed"]; got != 1 {
+		t.Errorf("%d jobs completed, want the running one to finish", got)
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+
+	store := newMemStore()
+	runner := newTestRunner(store)
+
+	started := make(chan struct{})
+	Register(runner, func(ctx context.Context, args slowArgs) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	store.enqueue(t, slowArgs{}, Options{})
+
+	runner.Start(context.Background())
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := runner.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown() error = %v, want deadline exceeded", err)
+	}
+}
+
+func TestPeriodicEnqueuesOncePerSlot(t *testing.T) {
+
+	store := newMemStore()
+	var calls atomic.Int32
+
+	// two replicas sharing one queue
+	for range 2 {
+		runner := newTestRunner(store)
+		Register(runner, func(ctx context.Context, args slowArgs) error {
+			calls.Add(1)
+			return nil
+		})
+		runner.Periodic(time.Hour, slowArgs{}, Options{})
+		runner.Start(context.Background())
+		defer runner.Shutdown(context.Background())
+	}
+
+	waitFor(t, func() bool { return store.statuses()["completed"] == 1 })
+	time.Sleep(30 * time.Millisecond)
+
+	if calls.Load() != 1 {
+		t.Errorf("periodic job ran %d times in one slot, want 1", calls.Load())
+	}
+}
+
+func TestDefaultBackoff(t *testing.T) {
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{4, 80 * time.Second},
+		{20, time.Hour},
+	}
+
+	for _, tc := range tests {
+		if got := DefaultBackoff(tc.attempt); got != tc.want {
+			t.Errorf("DefaultBackoff(%d) = %v, want %v", tc.attempt, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Package logging sets up Chirpy's structured logs.
+//
+// Logs are JSON, one object per line. Attributes whose key names a
+// credential (authorization, password, token and so on) are redacted by the
+// handler wherever they appear, so a careless log call can't leak them.
+// Each request carries a logger in its context, already tagged with the
+// request ID.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader is read from requests and echoed on responses.
+const RequestIDHeader = "X-Request-ID"
+
+const redacted = "[redacted]"
+
+// sensitive lists attribute keys, lower case, whose values are never logged.
+var sensitive = map[string]bool{
+	"authorization": true,
+	"cookie":        true,
+	"set-cookie":    true,
+	"password":      true,
+	"new_password":  true,
+	"secret":        true,
+	"token":         true,
+	"access_token":  true,
+	"refresh_token": true,
+	"api_key":       true,
+}
+
+// New returns a JSON logger writing to w at level and above.
+func New(w io.Writer, level slog.Leveler) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redact,
+	}))
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+
+	if sensitive[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+	return a
+}
+
+// Headers logs h as a group, one attribute per header. Sensitive headers
+// are redacted by the handler like any other attribute.
+func Headers(h http.Header) slog.Value {
+
+	attrs := []slog.Attr{}
+	for name, values := range h {
+		attrs = append(attrs, slog.String(name, strings.Join(values, ", ")))
+	}
+	return slog.GroupValue(attrs...)
+}
+
+type loggerKey struct{}
+type requestIDKey struct{}
+
+// WithLogger returns a copy of ctx carrying l.
+func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// FromContext returns the logger in ctx, or the default logger.
+func FromContext(ctx context.Context) *slog.Logger {
+
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+	return slog.Default()
+}
+
+// WithRequestID returns a copy of ctx carrying id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFrom returns the request ID in ctx, or "".
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// RequestID returns the ID the caller sent in RequestIDHeader, so a request
+// can be followed across services, or a new one if it's missing or doesn't
+// look like an ID.
+func RequestID(h http.Header) string {
+
+	id := h.Get(RequestIDHeader)
+	if validRequestID(id) {
+		return id
+	}
+	return uuid.NewString()
+}
+
+// validRequestID accepts short IDs made of characters that can't break a
+// log line or a header.
+func validRequestID(id string) bool {
+
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		ok := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			strings.ContainsRune("-_.:=", c)
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
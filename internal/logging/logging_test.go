@@ -0,0 +1,78 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRedaction(t *testing.T) {
+
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo)
+
+	h := http.Header{}
+	h.Set("Authorization", "Bearer eyJhbGciOi")
+	h.Set("User-Agent", "curl/8.0")
+	logger.Info("request",
+		"password", "hunter2",
+		slog.Group("body", "Refresh_Token", "abc123", "email", "a@example.com"),
+		"headers", Headers(h),
+	)
+
+	out := buf.String()
+	for _, secret := range []string{"hunter2", "abc123", "eyJhbGciOi"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("log leaks %q: %s", secret, out)
+		}
+	}
+	for _, want := range []string{`"password":"[redacted]"`, `"Authorization":"[redacted]"`, `"email":"a@example.com"`, `"User-Agent":"curl/8.0"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log is missing %s: %s", want, out)
+		}
+	}
+}
+
+func TestRequestID(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		given string
+		keep  bool
+	}{
+		{"missing", "", false},
+		{"uuid", "5f0c6a8e-2d1b-4f57-9d0e-9a3c1b2e7f10", true},
+		{"upstream style", "Root=1-67891233:abcdef_01.2", true},
+		{"newline", "abc\ninjected", false},
+		{"too long", strings.Repeat("a", 129), false},
+	}
+
+	for _, tc := range tests {
+		h := http.Header{}
+		if tc.given != "" {
+			h.Set(RequestIDHeader, tc.given)
+		}
+		got := RequestID(h)
+		if kept := got == tc.given; kept != tc.keep {
+			t.Errorf("%s: RequestID() = %q, kept = %v, want %v", tc.name, got, kept, tc.keep)
+		}
+		if got == "" {
+			t.Errorf("%s: RequestID() is empty", tc.name)
+		}
+	}
+}
+
+func TestFromContext(t *testing.T) {
+
+	if FromContext(context.Background()) != slog.Default() {
+		t.Error("FromContext() without a logger isn't the default logger")
+	}
+
+	l := slog.New(slog.NewJSONHandler(&bytes.Buffer{}, nil))
+	if FromContext(WithLogger(context.Background(), l)) != l {
+		t.Error("FromContext() doesn't return the stored logger")
+	}
+}
@@ -0,0 +1,143 @@
+// Package media validates uploaded images and prepares them for storage.
+//
+// Every upload is decoded and re-encoded, which drops EXIF, XMP and any
+// other metadata the client sent along. The only piece of EXIF we honour
+// is the JPEG orientation tag, which is applied to the pixels first so
+// photos don't end up sideways once it is gone.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported media type")
+	ErrTooManyPixels   = errors.New("image dimensions are too large")
+)
+
+type Options struct {
+	// MaxPixels caps width*height to guard against decompression bombs.
+	MaxPixels int
+	// ThumbnailSize is the bounding box thumbnails are scaled into.
+	ThumbnailSize int
+}
+
+var DefaultOptions = Options{
+	MaxPixels:     24_000_000,
+	ThumbnailSize: 320,
+}
+
+type Image struct {
+	ContentType string
+	Width       int
+	Height      int
+	Data        []byte
+}
+
+type Result struct {
+	Original  Image
+	Thumbnail Image
+}
+
+// Process sniffs, decodes, normalises and re-encodes an uploaded image and
+// renders a thumbnail for it.
+func Process(data []byte, opts Options) (Result, error) {
+
+	sniffed := http.DetectContentType(data)
+	switch sniffed {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+	default:
+		return Result{}, ErrUnsupportedType
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Result{}, ErrUnsupportedType
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > opts.MaxPixels {
+		return Result{}, ErrTooManyPixels
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Result{}, ErrUnsupportedType
+	}
+
+	if sniffed == "image/jpeg" {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	// jpeg stays jpeg; everything else becomes png so transparency survives.
+	// Animated gifs are reduced to their first frame.
+	outType := "image/png"
+	if sniffed == "image/jpeg" {
+		outType = "image/jpeg"
+	}
+
+	original, err := encode(img, outType)
+	if err != nil {
+		return Result{}, err
+	}
+
+	thumb, err := encode(thumbnail(img, opts.ThumbnailSize), outType)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{Original: original, Thumbnail: thumb}, nil
+}
+
+func thumbnail(img image.Image, size int) image.Image {
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+
+	if w >= h {
+		h = max(1, h*size/w)
+		w = size
+	} else {
+		w = max(1, w*size/h)
+		h = size
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Over, nil)
+	return dst
+}
+
+func encode(img image.Image, contentType string) (Image, error) {
+
+	var buf bytes.Buffer
+	var err error
+	switch contentType {
+	case "image/jpeg":
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90})
+	case "image/gif":
+		err = gif.Encode(&buf, img, nil)
+	default:
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return Image{}, err
+	}
+
+	b := img.Bounds()
+	return Image{
+		ContentType: contentType,
+		Width:       b.Dx(),
+		Height:      b.Dy(),
+		Data:        buf.Bytes(),
+	}, nil
+}
@@ -0,0 +1,126 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func testImage(w, h int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+// withExifOrientation splices an APP1 segment carrying only an orientation
+// tag in right after the SOI marker of a JPEG.
+func withExifOrientation(jpg []byte, orientation byte) []byte {
+	tiff := []byte{
+		'M', 'M', 0x00, 0x2A, 0x00, 0x00, 0x00, 0x08, // header, IFD0 at offset 8
+		0x00, 0x01, // one entry
+		0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, orientation, 0x00, 0x00,
+		0x00, 0x00, 0x00, 0x00, // no next IFD
+	}
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	length := len(payload) + 2
+
+	out := []byte{0xFF, 0xD8, 0xFF, 0xE1, byte(length >> 8), byte(length)}
+	out = append(out, payload...)
+	return append(out, jpg[2:]...)
+}
+
+func TestProcessStripsExifAndAppliesOrientation(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(40, 20), nil); err != nil {
+		t.Fatal(err)
+	}
+	data := withExifOrientation(buf.Bytes(), 6)
+
+	if got := jpegOrientation(data); got != 6 {
+		t.Fatalf("jpegOrientation() = %d, want 6", got)
+	}
+
+	res, err := Process(data, DefaultOptions)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	if bytes.Contains(res.Original.Data, []byte("Exif")) {
+		t.Errorf("Process() kept EXIF data in the original")
+	}
+	if res.Original.Width != 20 || res.Original.Height != 40 {
+		t.Errorf("Process() size = %dx%d, want 20x40", res.Original.Width, res.Original.Height)
+	}
+	if res.Original.ContentType != "image/jpeg" {
+		t.Errorf("Process() content type = %q, want image/jpeg", res.Original.ContentType)
+	}
+}
+
+func TestProcessThumbnail(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage(1000, 500)); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := Process(buf.Bytes(), DefaultOptions)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+
+	if res.Thumbnail.Width != 320 || res.Thumbnail.Height != 160 {
+		t.Errorf("thumbnail size = %dx%d, want 320x160", res.Thumbnail.Width, res.Thumbnail.Height)
+	}
+	if res.Thumbnail.ContentType != "image/png" {
+		t.Errorf("thumbnail content type = %q, want image/png", res.Thumbnail.ContentType)
+	}
+}
+
+func TestProcessRejects(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage(100, 100)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		opts    Options
+		wantErr error
+	}{
+		{
+			name:    "Not an image",
+			data:    []byte("<html><body>hi</body></html>"),
+			opts:    DefaultOptions,
+			wantErr: ErrUnsupportedType,
+		},
+		{
+			name:    "Truncated png",
+			data:    buf.Bytes()[:20],
+			opts:    DefaultOptions,
+			wantErr: ErrUnsupportedType,
+		},
+		{
+			name:    "Too many pixels",
+			data:    buf.Bytes(),
+			opts:    Options{MaxPixels: 50, ThumbnailSize: 10},
+			wantErr: ErrTooManyPixels,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Process(tt.data, tt.opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Process() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+// Package password decides whether a new password is acceptable.
+//
+// The rules follow NIST SP 800-63B: a minimum length, no composition rules,
+// and a check against passwords known to be breached or commonly used.
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxBytes is the most bcrypt looks at. Longer passwords would be silently
+// truncated, so they are refused instead.
+const MaxBytes = 72
+
+// codes of Error
+const (
+	CodeTooShort       = "too_short"
+	CodeTooLong        = "too_long"
+	CodeBreached       = "breached"
+	CodeMatchesAccount = "matches_account"
+)
+
+// Error says why a password was refused.
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+//go:embed common.txt
+var commonPasswords string
+
+// List is a set of passwords that mustn't be used, kept as SHA-1 hashes.
+type List struct {
+	hashes map[string]struct{}
+}
+
+// CommonList is the list built into Chirpy.
+func CommonList() *List {
+	l := &List{hashes: map[string]struct{}{}}
+	l.Read(strings.NewReader(commonPasswords))
+	return l
+}
+
+// ReadList reads a new list from r. See Read for the format.
+func ReadList(r io.Reader) (*List, error) {
+	l := &List{hashes: map[string]struct{}{}}
+	return l, l.Read(r)
+}
+
+// Load adds the entries in the file at path to l.
+func (l *List) Load(path string) error {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = l.Read(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// Read adds entries to l, one per line. An entry is either a password,
+// compared case-insensitively, or the SHA-1 of one in hex with an optional
+// ":count" suffix, as in the Have I Been Pwned downloads. Blank lines and
+// lines starting with # are skipped.
+func (l *List) Read(r io.Reader) error {
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if hash, _, _ := strings.Cut(line, ":"); isSHA1(hash) {
+			l.hashes[strings.ToUpper(hash)] = struct{}{}
+			continue
+		}
+		l.hashes[hashOf(strings.ToLower(line))] = struct{}{}
+	}
+	return scanner.Err()
+}
+
+// Len is the number of entries in l.
+func (l *List) Len() int {
+	return len(l.hashes)
+}
+
+// Contains reports whether pw is on the list.
+func (l *List) Contains(pw string) bool {
+	if _, ok := l.hashes[hashOf(pw)]; ok {
+		return true
+	}
+	_, ok := l.hashes[hashOf(strings.ToLower(pw))]
+	return ok
+}
+
+func hashOf(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func isSHA1(s string) bool {
+	if len(s) != 40 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// Policy is what a new password must satisfy.
+type Policy struct {
+	MinLength int
+	// Breached is checked when it isn't nil.
+	Breached *List
+}
+
+// Check returns an *Error if pw breaks the policy. account holds things a
+// password mustn't simply repeat, like the user's email and handle.
+func (p Policy) Check(pw string, account ...string) error {
+
+	if n := utf8.RuneCountInString(pw); n < p.MinLength {
+		return &Error{Code: CodeTooShort, Message: fmt.Sprintf("must be at least %d characters", p.MinLength)}
+	}
+	if len(pw) > MaxBytes {
+		return &Error{Code: CodeTooLong, Message: fmt.Sprintf("must be at most %d bytes", MaxBytes)}
+	}
+
+	lower := strings.ToLower(pw)
+	for _, s := range account {
+		s = strings.ToLower(strings.TrimSpace(s))
+		local, _, _ := strings.Cut(s, "@")
+		if s != "" && (lower == s || lower == local) {
+			return &Error{Code: CodeMatchesAccount, Message: "mustn't be your email or handle"}
+		}
+	}
+
+	if p.Breached != nil && p.Breached.Contains(pw) {
+		return &Error{Code: CodeBreached, Message: "is too common or has appeared in a data breach, choose another"}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	// SHA-1 of "correct horse battery", as a breach dump would list it
+	list, err := ReadList(strings.NewReader("# comment\n\nHunter2\n" + hashOf("correct horse battery") + ":42\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := Policy{MinLength: 8, Breached: list}
+
+	tests := []struct {
+		pw   string
+		want string
+	}{
+		{"Tr0ub4dor&3x", ""},
+		{"short", CodeTooShort},
+		{strings.Repeat("é", 40), CodeTooLong},
+		{"HUNTER2hunter2", ""},
+		{"correct horse battery", CodeBreached},
+		{"bob@example.com", CodeMatchesAccount},
+		{"BobTheBuilder", CodeMatchesAccount},
+		{"bobthebuilder1", ""},
+	}
+	for _, tc := range tests {
+		err := policy.Check(tc.pw, "bob@example.com", "bobthebuilder")
+		var pw_err *Error
+		switch {
+		case tc.want == "" && err != nil:
+			t.Errorf("Check(%q) = %v, want nil", tc.pw, err)
+		case tc.want != "" && (!errors.As(err, &pw_err) || pw_err.Code != tc.want):
+			t.Errorf("Check(%q) = %v, want %s", tc.pw, err, tc.want)
+		}
+	}
+
+	short := Policy{MinLength: 1, Breached: list}
+	if err := short.Check("hunter2"); err == nil {
+		t.Error("entries should match case-insensitively")
+	}
+}
+
+func TestCommonList(t *testing.T) {
+	l := CommonList()
+	if l.Len() < 100 {
+		t.Errorf("built-in list has %d entries", l.Len())
+	}
+	if !l.Contains("Password123") {
+		t.Error("Password123 should be on the built-in list")
+	}
+}
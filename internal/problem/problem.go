@@ -0,0 +1,216 @@
+// Package problem builds the RFC 7807 error responses Chirpy sends.
+//
+// Every error carries a Code from the catalogue below. Codes are stable:
+// clients switch on them, so an existing code never changes meaning and
+// is never reused. Title and Detail are for people and may change.
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+)
+
+// ContentType is the media type of a problem response.
+const ContentType = "application/problem+json"
+
+// TypeBase prefixes a code to form the problem's type URI. The server
+// describes each type at that path.
+const TypeBase = "/problems/"
+
+type Code string
+
+const (
+	CodeBadRequest           Code = "bad_request"
+	CodeMalformedJSON        Code = "malformed_json"
+	CodeUnsupportedMediaType Code = "unsupported_media_type"
+	CodeBodyTooLarge         Code = "body_too_large"
+	CodeValidationFailed     Code = "validation_failed"
+	CodeUnauthorized         Code = "unauthorized"
+	CodeInvalidToken         Code = "invalid_token"
+	CodeInvalidCredentials   Code = "invalid_credentials"
+	CodeInvalidSignature     Code = "invalid_signature"
+	CodeForbidden            Code = "forbidden"
+	CodeAccountRestricted    Code = "account_restricted"
+	CodeNotFound             Code = "not_found"
+	CodeConflict             Code = "conflict"
+	CodeRateLimited          Code = "rate_limited"
+	CodeQuotaExceeded        Code = "quota_exceeded"
+	CodeChirpEmpty           Code = "chirp_empty"
+	CodeChirpTooLong         Code = "chirp_too_long"
+	CodeChirpInvalidEncoding Code = "chirp_invalid_encoding"
+	CodeChirpInvalidChar     Code = "chirp_invalid_character"
+	CodeChirpRejected        Code = "chirp_rejected"
+	CodeInternal             Code = "internal_error"
+	CodeUnavailable          Code = "service_unavailable"
+)
+
+// Type describes one kind of problem.
+type Type struct {
+	Code        Code   `json:"code"`
+	Status      int    `json:"status"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+var catalogue = map[Code]Type{
+	CodeBadRequest:           {Status: http.StatusBadRequest, Title: "Bad request", Description: "The request can't be processed as sent."},
+	CodeMalformedJSON:        {Status: http.StatusBadRequest, Title: "Malformed JSON", Description: "The request body isn't valid JSON."},
+	CodeUnsupportedMediaType: {Status: http.StatusUnsupportedMediaType, Title: "Unsupported media type", Description: "The request body or uploaded file is of a type this endpoint doesn't accept."},
+	CodeBodyTooLarge:         {Status: http.StatusRequestEntityTooLarge, Title: "Body too large", Description: "The request body or uploaded file is over the size limit."},
+	CodeValidationFailed:     {Status: http.StatusUnprocessableEntity, Title: "Validation failed", Description: "One or more fields are missing or invalid. The errors member lists them."},
+	CodeUnauthorized:         {Status: http.StatusUnauthorized, Title: "Authentication required", Description: "The endpoint needs a token and none was sent."},
+	CodeInvalidToken:         {Status: http.StatusUnauthorized, Title: "Invalid token", Description: "The token sent is malformed, expired, revoked or belongs to a deleted user."},
+	CodeInvalidCredentials:   {Status: http.StatusUnauthorized, Title: "Invalid credentials", Description: "The email or password is wrong."},
+	CodeInvalidSignature:     {Status: http.StatusUnauthorized, Title: "Invalid signature", Description: "The webhook signature didn't verify."},
+	CodeForbidden:            {Status: http.StatusForbidden, Title: "Forbidden", Description: "The caller isn't allowed to do this."},
+	CodeAccountRestricted:    {Status: http.StatusForbidden, Title: "Account restricted", Description: "The account is suspended or banned. The standing member says until when."},
+	CodeNotFound:             {Status: http.StatusNotFound, Title: "Not found", Description: "The resource doesn't exist or the caller can't see it."},
+	CodeConflict:             {Status: http.StatusConflict, Title: "Conflict", Description: "The request conflicts with the current state of the resource."},
+	CodeRateLimited:          {Status: http.StatusTooManyRequests, Title: "Rate limited", Description: "Too many requests. Retry after the number of seconds in Retry-After."},
+	CodeQuotaExceeded:        {Status: http.StatusTooManyRequests, Title: "Quota exceeded", Description: "The caller's plan doesn't allow more of this for now."},
+	CodeChirpEmpty:           {Status: http.StatusBadRequest, Title: "Chirp is empty", Description: "The chirp body has no text."},
+	CodeChirpTooLong:         {Status: http.StatusBadRequest, Title: "Chirp is too long", Description: "The chirp body is over the plan's limit. The length and limit members give the numbers."},
+	CodeChirpInvalidEncoding: {Status: http.StatusBadRequest, Title: "Chirp isn't valid UTF-8", Description: "The chirp body isn't valid UTF-8."},
+	CodeChirpInvalidChar:     {Status: http.StatusBadRequest, Title: "Chirp contains a disallowed character", Description: "The chirp body contains a character that isn't allowed. The character and offset members say which and where."},
+	CodeChirpRejected:        {Status: http.StatusBadRequest, Title: "Chirp rejected", Description: "The chirp matched a content filter rule that blocks it."},
+	CodeInternal:             {Status: http.StatusInternalServerError, Title: "Internal error", Description: "Something went wrong on the server. Retrying may help."},
+	CodeUnavailable:          {Status: http.StatusServiceUnavailable, Title: "Service unavailable", Description: "The server can't take the request right now."},
+}
+
+// Lookup returns the catalogue entry for code.
+func Lookup(code Code) (Type, bool) {
+	t, ok := catalogue[code]
+	t.Code = code
+	return t, ok
+}
+
+// Catalogue lists every problem type, sorted by code.
+func Catalogue() []Type {
+	types := make([]Type, 0, len(catalogue))
+	for code := range catalogue {
+		t, _ := Lookup(code)
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].Code < types[j].Code })
+	return types
+}
+
+// ForStatus is the generic code for an HTTP status, for errors nothing more
+// specific is known about.
+func ForStatus(status int) Code {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusRequestEntityTooLarge:
+		return CodeBodyTooLarge
+	case http.StatusUnsupportedMediaType:
+		return CodeUnsupportedMediaType
+	case http.StatusUnprocessableEntity:
+		return CodeValidationFailed
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
+
+// FieldError is one invalid field in a request body.
+type FieldError struct {
+	// Field is the JSON path of the field, like "poll.options".
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Problem is an RFC 7807 problem details object.
+type Problem struct {
+	Type   string
+	Title  string
+	Status int
+	Detail string
+	Code   Code
+	Errors []FieldError
+	// Extensions are extra top-level members, like a chirp's length.
+	Extensions map[string]any
+}
+
+// New returns a problem of the given code. status overrides the
+// catalogue's status when it isn't zero.
+func New(code Code, status int, detail string) *Problem {
+	t, _ := Lookup(code)
+	if status == 0 {
+		status = t.Status
+	}
+	return &Problem{
+		Type:   TypeBase + string(code),
+		Title:  t.Title,
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// Validation returns a validation_failed problem listing errs.
+func Validation(errs ...FieldError) *Problem {
+	p := New(CodeValidationFailed, 0, "")
+	p.Detail = "the request has invalid fields"
+	if len(errs) == 1 {
+		p.Detail = errs[0].Field + ": " + errs[0].Message
+	}
+	p.Errors = errs
+	return p
+}
+
+// With sets an extension member and returns p.
+func (p *Problem) With(key string, value any) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = map[string]any{}
+	}
+	p.Extensions[key] = value
+	return p
+}
+
+func (p *Problem) MarshalJSON() ([]byte, error) {
+
+	members := map[string]any{}
+	for k, v := range p.Extensions {
+		members[k] = v
+	}
+	members["type"] = p.Type
+	members["title"] = p.Title
+	members["status"] = p.Status
+	members["code"] = p.Code
+	if p.Detail != "" {
+		members["detail"] = p.Detail
+	}
+	if len(p.Errors) > 0 {
+		members["errors"] = p.Errors
+	}
+	return json.Marshal(members)
+}
+
+// Write sends p as the response.
+func Write(w http.ResponseWriter, p *Problem) error {
+
+	dat, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	_, err = w.Write(dat)
+	return err
+}
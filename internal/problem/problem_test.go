@@ -0,0 +1,66 @@
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCatalogueIsComplete(t *testing.T) {
+	for _, typ := range Catalogue() {
+		if typ.Status < 400 || typ.Title == "" || typ.Description == "" {
+			t.Errorf("%s: incomplete entry %+v", typ.Code, typ)
+		}
+	}
+	for _, status := range []int{400, 401, 403, 404, 409, 413, 415, 422, 429, 500, 502, 503} {
+		if _, ok := Lookup(ForStatus(status)); !ok {
+			t.Errorf("ForStatus(%d) isn't in the catalogue", status)
+		}
+	}
+}
+
+func TestWrite(t *testing.T) {
+	w := httptest.NewRecorder()
+	p := New(CodeChirpTooLong, 0, "chirp is too long").With("limit", 140)
+	if err := Write(w, p); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := w.Header().Get("Content-Type"); got != ContentType {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d", w.Code)
+	}
+	body := map[string]any{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"type":   "/problems/chirp_too_long",
+		"title":  "Chirp is too long",
+		"status": 400.0,
+		"code":   "chirp_too_long",
+		"detail": "chirp is too long",
+		"limit":  140.0,
+	}
+	for k, v := range want {
+		if body[k] != v {
+			t.Errorf("%s = %v, want %v", k, body[k], v)
+		}
+	}
+	if _, ok := body["errors"]; ok {
+		t.Error("errors should be left out when there are none")
+	}
+}
+
+func TestValidation(t *testing.T) {
+	p := Validation(
+		FieldError{Field: "email", Code: "required", Message: "is required"},
+		FieldError{Field: "password", Code: "too_short", Message: "must be at least 8 characters"},
+	)
+	if p.Status != http.StatusUnprocessableEntity || p.Code != CodeValidationFailed || len(p.Errors) != 2 {
+		t.Errorf("got %+v", p)
+	}
+}
@@ -0,0 +1,169 @@
+// Package ratelimit decides whether a request fits within a Limit.
+//
+// Limits are token buckets holding Limit.Requests tokens, refilled evenly
+// over Limit.Per. They are implemented with GCRA: instead of a token count
+// each key keeps a theoretical arrival time (TAT), the time at which its
+// bucket would be full again. That is one timestamp per key, which a
+// database can update atomically in a single statement, so limits hold
+// across replicas when the Store is shared.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit allows Requests per Per, in bursts of up to Requests. The zero
+// Limit allows everything.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// Unlimited reports whether l lets everything through.
+func (l Limit) Unlimited() bool {
+	return l.Requests <= 0 || l.Per <= 0
+}
+
+// Interval is how long one token takes to come back.
+func (l Limit) Interval() time.Duration {
+	return l.Per / time.Duration(l.Requests)
+}
+
+// String formats l the way UnmarshalText reads it, such as "10/1m0s".
+func (l Limit) String() string {
+	if l.Unlimited() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, l.Per)
+}
+
+// UnmarshalText reads "<requests>/<duration>", such as "10/1m", or "off".
+func (l *Limit) UnmarshalText(text []byte) error {
+
+	s := string(text)
+	if s == "off" {
+		*l = Limit{}
+		return nil
+	}
+
+	count, per, ok := strings.Cut(s, "/")
+	if !ok {
+		return fmt.Errorf("%q isn't a limit like 10/1m or off", s)
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil || n <= 0 {
+		return fmt.Errorf("%q isn't a limit like 10/1m or off: bad request count", s)
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("%q isn't a limit like 10/1m or off: bad duration", s)
+	}
+
+	*l = Limit{Requests: n, Per: d}
+	return nil
+}
+
+// Result says whether a request was allowed and what is left.
+type Result struct {
+	Allowed bool
+	// Remaining is how many more requests would be allowed right now.
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long to wait before the next request is allowed.
+	// It is zero when Allowed.
+	RetryAfter time.Duration
+}
+
+// Store keeps the state of every key.
+type Store interface {
+	// Allow takes a token for key if one is available.
+	Allow(ctx context.Context, key string, l Limit) (Result, error)
+}
+
+// Decide applies GCRA: tat is the key's theoretical arrival time (zero for
+// a new key), now the current time. It returns the new TAT to store, or tat
+// unchanged if the request is refused.
+func Decide(tat, now time.Time, l Limit) (time.Time, Result) {
+
+	if l.Unlimited() {
+		return tat, Result{Allowed: true, Remaining: math.MaxInt32}
+	}
+
+	interval := l.Interval()
+	next := tat
+	if next.Before(now) {
+		next = now
+	}
+	next = next.Add(interval)
+
+	// the bucket would hold more than Per worth of debt: refuse
+	if next.Sub(now) > l.Per {
+		return tat, Result{
+			Allowed:    false,
+			Reset:      tat.Sub(now),
+			RetryAfter: next.Sub(now) - l.Per,
+		}
+	}
+
+	return next, Taken(next, now, l)
+}
+
+// Taken describes the bucket after a request was allowed and tat stored
+// for it, for stores that run the GCRA step themselves.
+func Taken(tat, now time.Time, l Limit) Result {
+	return Result{
+		Allowed:   true,
+		Remaining: int((l.Per - tat.Sub(now)) / l.Interval()),
+		Reset:     tat.Sub(now),
+	}
+}
+
+// MemoryStore keeps state in this process. Limits aren't shared between
+// replicas.
+type MemoryStore struct {
+	// Now returns the current time. Nil means time.Now.
+	Now func() time.Time
+
+	mu   sync.Mutex
+	tats map[string]time.Time
+	// calls counts Allow calls, to sweep full buckets now and then
+	calls int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{tats: map[string]time.Time{}}
+}
+
+const sweepEvery = 10000
+
+func (s *MemoryStore) Allow(ctx context.Context, key string, l Limit) (Result, error) {
+
+	now := time.Now()
+	if s.Now != nil {
+		now = s.Now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tat, res := Decide(s.tats[key], now, l)
+	s.tats[key] = tat
+
+	// a TAT in the past is a full bucket, the same as no entry at all
+	s.calls++
+	if s.calls%sweepEvery == 0 {
+		for k, t := range s.tats {
+			if t.Before(now) {
+				delete(s.tats, k)
+			}
+		}
+	}
+	return res, nil
+}
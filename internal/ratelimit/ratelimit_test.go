@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBurstThenRefill(t *testing.T) {
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.Now = func() time.Time { return now }
+	limit := Limit{Requests: 3, Per: time.Minute}
+
+	for i, wantRemaining := range []int{2, 1, 0} {
+		res, _ := store.Allow(context.Background(), "ip:1", limit)
+		if !res.Allowed || res.Remaining != wantRemaining {
+			t.Fatalf("request %d = %+v, want allowed with %d remaining", i+1, res, wantRemaining)
+		}
+	}
+
+	res, _ := store.Allow(context.Background(), "ip:1", limit)
+	if res.Allowed {
+		t.Fatal("fourth request in a burst of 3 was allowed")
+	}
+	if res.RetryAfter != 20*time.Second {
+		t.Errorf("RetryAfter = %v, want 20s for one token at 3/min", res.RetryAfter)
+	}
+
+	// other keys have their own bucket
+	res, _ = store.Allow(context.Background(), "ip:2", limit)
+	if !res.Allowed {
+		t.Error("a different key was refused")
+	}
+
+	// one token comes back every 20s
+	now = now.Add(20 * time.Second)
+	res, _ = store.Allow(context.Background(), "ip:1", limit)
+	if !res.Allowed || res.Remaining != 0 {
+		t.Errorf("after 20s = %+v, want allowed with 0 remaining", res)
+	}
+
+	// and the bucket is full again after a whole period
+	now = now.Add(time.Minute)
+	res, _ = store.Allow(context.Background(), "ip:1", limit)
+	if !res.Allowed || res.Remaining != 2 {
+		t.Errorf("after a minute = %+v, want allowed with 2 remaining", res)
+	}
+}
+
+func TestRefusedRequestsDontConsume(t *testing.T) {
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	limit := Limit{Requests: 1, Per: time.Second}
+
+	tat, _ := Decide(time.Time{}, now, limit)
+	for range 5 {
+		var res Result
+		tat, res = Decide(tat, now, limit)
+		if res.Allowed {
+			t.Fatal("request over the limit was allowed")
+		}
+	}
+
+	_, res := Decide(tat, now.Add(time.Second), limit)
+	if !res.Allowed {
+		t.Error("refused requests pushed back the next allowed one")
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+
+	tests := []struct {
+		in   string
+		want Limit
+		err  bool
+	}{
+		{"10/1m", Limit{Requests: 10, Per: time.Minute}, false},
+		{"off", Limit{}, false},
+		{"10", Limit{}, true},
+		{"0/1m", Limit{}, true},
+		{"10/soon", Limit{}, true},
+	}
+
+	for _, tc := range tests {
+		var got Limit
+		err := got.UnmarshalText([]byte(tc.in))
+		if (err != nil) != tc.err || got != tc.want {
+			t.Errorf("UnmarshalText(%q) = %v, %v; want %v, error %v", tc.in, got, err, tc.want, tc.err)
+		}
+	}
+
+	if s := (Limit{Requests: 10, Per: time.Minute}).String(); s != "10/1m0s" {
+		t.Errorf("String() = %q", s)
+	}
+}
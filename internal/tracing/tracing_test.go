@@ -0,0 +1,74 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func TestFileExporter(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	flush, err := Setup(context.Background(), Config{
+		ServiceName: "chirpy-test",
+		Exporter:    ExporterFile,
+		File:        path,
+		SampleRatio: 1,
+	})
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+
+	_, span := otel.Tracer("test").Start(context.Background(), "GetUserByID")
+	span.End()
+
+	err = flush(context.Background())
+	if err != nil {
+		t.Fatalf("flush error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("couldn't read trace file: %v", err)
+	}
+	for _, want := range []string{`"Name":"GetUserByID"`, "chirpy-test"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("trace file is missing %s:\n%s", want, data)
+		}
+	}
+}
+
+func TestPropagation(t *testing.T) {
+
+	_, err := Setup(context.Background(), Config{Exporter: ExporterNone})
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	in := http.Header{}
+	in.Set("traceparent", traceparent)
+
+	prop := otel.GetTextMapPropagator()
+	ctx := prop.Extract(context.Background(), propagation.HeaderCarrier(in))
+	out := http.Header{}
+	prop.Inject(ctx, propagation.HeaderCarrier(out))
+
+	if got := out.Get("traceparent"); got != traceparent {
+		t.Errorf("traceparent = %q, want %q passed through", got, traceparent)
+	}
+}
+
+func TestUnknownExporter(t *testing.T) {
+
+	_, err := Setup(context.Background(), Config{Exporter: "jaeger"})
+	if err == nil {
+		t.Fatal("Setup() with an unknown exporter succeeded")
+	}
+}
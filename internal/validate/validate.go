@@ -0,0 +1,293 @@
+// Package validate checks request structs against rules declared in their
+// `validate` struct tags:
+//
+//	type parameters struct {
+//		Email   string `json:"email" validate:"required,email"`
+//		Reason  string `json:"reason" validate:"required,oneof=spam other"`
+//		Details string `json:"details" validate:"max=500"`
+//	}
+//
+// The rules are:
+//
+//	required   the value isn't the zero value; strings must have more than whitespace
+//	min=N      strings have at least N characters, slices N elements, numbers are at least N
+//	max=N      strings have at most N characters, slices N elements, numbers are at most N
+//	oneof=a b  the value is one of the space-separated words
+//	email      the value is a plain email address, see Email
+//
+// Apart from required, rules on strings, slices and pointers only apply
+// when a value is present, so optional fields need no extra marker. Rules
+// on numbers always apply. Struct fields, pointers to structs and slices of
+// structs are checked recursively.
+package validate
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError is one rule a field broke.
+type FieldError struct {
+	// Field is the JSON path of the field, like "poll.options[2]".
+	Field   string
+	Code    string
+	Message string
+}
+
+// Errors is every rule a struct broke, in field order.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, f := range e {
+		msgs[i] = f.Field + ": " + f.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Struct checks v, a struct or a pointer to one. It returns Errors when
+// any rule fails, and panics if a tag is malformed, since that is a bug.
+func Struct(v any) error {
+
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	errs := Errors{}
+	checkStruct(rv, "", &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func checkStruct(rv reflect.Value, prefix string, errs *Errors) {
+
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name := jsonName(sf)
+		if name == "-" {
+			continue
+		}
+		if sf.Anonymous && sf.Tag.Get("json") == "" {
+			// embedded fields are flattened into the parent object
+			checkValue(rv.Field(i), prefix, "", errs)
+			continue
+		}
+		checkValue(rv.Field(i), join(prefix, name), sf.Tag.Get("validate"), errs)
+	}
+}
+
+func checkValue(fv reflect.Value, path, tag string, errs *Errors) {
+
+	rules := []string{}
+	if tag != "" {
+		rules = strings.Split(tag, ",")
+	}
+
+	for _, rule := range rules {
+		if rule == "required" {
+			if isEmpty(fv) {
+				*errs = append(*errs, FieldError{Field: path, Code: "required", Message: "is required"})
+				return
+			}
+		}
+	}
+
+	v := fv
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	for _, rule := range rules {
+		name, arg, _ := strings.Cut(rule, "=")
+		if name == "required" {
+			continue
+		}
+		if isEmpty(v) && !isNumber(v) {
+			continue
+		}
+		if fe, ok := checkRule(v, name, arg); !ok {
+			fe.Field = path
+			*errs = append(*errs, fe)
+			// one complaint per field is enough to fix it
+			return
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		checkStruct(v, path, errs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			elem := v.Index(i)
+			for elem.Kind() == reflect.Pointer && !elem.IsNil() {
+				elem = elem.Elem()
+			}
+			if elem.Kind() == reflect.Struct {
+				checkStruct(elem, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+	}
+}
+
+func checkRule(v reflect.Value, name, arg string) (FieldError, bool) {
+
+	switch name {
+	case "min", "max":
+		n, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			panic(fmt.Sprintf("validate: bad %s=%q", name, arg))
+		}
+		size, unit := measure(v)
+		if name == "min" && size < n {
+			code := "too_short"
+			if unit == "" {
+				code = "too_small"
+			}
+			return FieldError{Code: code, Message: "must be at least " + arg + unit}, false
+		}
+		if name == "max" && size > n {
+			code := "too_long"
+			if unit == "" {
+				code = "too_large"
+			}
+			return FieldError{Code: code, Message: "must be at most " + arg + unit}, false
+		}
+
+	case "oneof":
+		options := strings.Fields(arg)
+		s := fmt.Sprint(v.Interface())
+		for _, o := range options {
+			if s == o {
+				return FieldError{}, true
+			}
+		}
+		return FieldError{Code: "invalid_choice", Message: "must be one of " + strings.Join(options, ", ")}, false
+
+	case "email":
+		// surrounding space is left for NormalizeEmail to trim
+		if v.Kind() != reflect.String || !Email(strings.TrimSpace(v.String())) {
+			return FieldError{Code: "invalid_email", Message: "must be a valid email address"}, false
+		}
+
+	default:
+		panic(fmt.Sprintf("validate: unknown rule %q", name))
+	}
+	return FieldError{}, true
+}
+
+// measure is what min and max compare: characters, elements or the value.
+func measure(v reflect.Value) (float64, string) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), ""
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), ""
+	}
+	panic(fmt.Sprintf("validate: min and max don't apply to %s", v.Kind()))
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(v.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	}
+	return v.IsZero()
+}
+
+func isNumber(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func jsonName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" {
+		return sf.Name
+	}
+	return name
+}
+
+func join(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
+
+// Email reports whether s is a bare email address like bob@example.com,
+// without a display name, comments or surrounding space.
+func Email(s string) bool {
+
+	if len(s) > 254 {
+		return false
+	}
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Address != s || addr.Name != "" {
+		return false
+	}
+	_, domain, _ := strings.Cut(s, "@")
+	return strings.Contains(domain, ".") && !strings.HasSuffix(domain, ".")
+}
+
+// NormalizeEmail is the form emails are stored and looked up in: trimmed
+// and lower case. Strictly the part before the @ is case sensitive, but no
+// mail provider people use treats it so, and users don't expect it.
+func NormalizeEmail(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+var handlePattern = regexp.MustCompile(`^[A-Za-z0-9_]{3,30}$`)
+
+// reservedHandles can't be claimed because they collide with routes. They
+// are lower case; handles are compared without regard to case.
+var reservedHandles = map[string]struct{}{
+	"me": {},
+}
+
+// Handle checks that s can be used as a user handle.
+func Handle(s string) error {
+	if !handlePattern.MatchString(s) {
+		return errors.New("handle must be 3-30 letters, digits or underscores")
+	}
+	if _, ok := reservedHandles[strings.ToLower(s)]; ok {
+		return errors.New("handle is reserved")
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package validate
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+type option struct {
+	Text string `json:"text" validate:"required,max=5"`
+}
+
+type params struct {
+	Email   string    `json:"email" validate:"required,email"`
+	Reason  string    `json:"reason" validate:"oneof=spam other"`
+	Details *string   `json:"details" validate:"max=3"`
+	Days    int       `json:"days" validate:"min=1,max=30"`
+	Tags    []string  `json:"tags" validate:"max=2"`
+	Options []option  `json:"options"`
+	Poll    *option   `json:"poll"`
+	When    time.Time `json:"when"`
+	skipped string    `validate:"required"`
+}
+
+func TestStruct(t *testing.T) {
+	long := "long details"
+	tests := []struct {
+		name  string
+		input params
+		want  []FieldError
+	}{
+		{
+			name:  "valid",
+			input: params{Email: " Bob@Example.com ", Days: 3, Options: []option{{Text: "yes"}}},
+		},
+		{
+			name: "every rule",
+			input: params{
+				Email:   "bob",
+				Reason:  "boredom",
+				Details: &long,
+				Days:    0,
+				Tags:    []string{"a", "b", "c"},
+				Options: []option{{Text: "ok"}, {Text: ""}, {Text: "too long"}},
+				Poll:    &option{},
+			},
+			want: []FieldError{
+				{Field: "email", Code: "invalid_email"},
+				{Field: "reason", Code: "invalid_choice"},
+				{Field: "details", Code: "too_long"},
+				{Field: "days", Code: "too_small"},
+				{Field: "tags", Code: "too_long"},
+				{Field: "options[1].text", Code: "required"},
+				{Field: "options[2].text", Code: "too_long"},
+				{Field: "poll.text", Code: "required"},
+			},
+		},
+		{
+			name:  "required ignores whitespace",
+			input: params{Email: "   ", Days: 1},
+			want:  []FieldError{{Field: "email", Code: "required"}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Struct(&tc.input)
+			if len(tc.want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var errs Errors
+			if !errors.As(err, &errs) {
+				t.Fatalf("err = %v, want Errors", err)
+			}
+			if len(errs) != len(tc.want) {
+				t.Fatalf("got %v, want %d errors", errs, len(tc.want))
+			}
+			for i, want := range tc.want {
+				if errs[i].Field != want.Field || errs[i].Code != want.Code {
+					t.Errorf("error %d = %+v, want %+v", i, errs[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestEmail(t *testing.T) {
+	valid := []string{"bob@example.com", "a.b+c@mail.example.org"}
+	invalid := []string{"", "bob", "bob@", "bob@localhost", "Bob <bob@example.com>", " bob@example.com", "bob@example."}
+	for _, s := range valid {
+		if !Email(s) {
+			t.Errorf("Email(%q) = false", s)
+		}
+	}
+	for _, s := range invalid {
+		if Email(s) {
+			t.Errorf("Email(%q) = true", s)
+		}
+	}
+	if got := NormalizeEmail("  Bob@Example.COM "); got != "bob@example.com" {
+		t.Errorf("NormalizeEmail = %q", got)
+	}
+}
+
+func TestHandle(t *testing.T) {
+	// "me" is too short to get past the pattern anyway, so reserve a
+	// longer handle to see that case doesn't get around the list
+	reservedHandles["settings"] = struct{}{}
+	defer delete(reservedHandles, "settings")
+
+	valid := []string{"bob", "Bob_99", "memes", "a_me", "settings2"}
+	invalid := []string{"", "ab", "bob smith", "bob!", "me", "ME", "settings", "Settings", "SETTINGS"}
+	for _, s := range valid {
+		if err := Handle(s); err != nil {
+			t.Errorf("Handle(%q) = %v", s, err)
+		}
+	}
+	for _, s := range invalid {
+		if Handle(s) == nil {
+			t.Errorf("Handle(%q) = nil, want an error", s)
+		}
+	}
+}
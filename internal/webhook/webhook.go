@@ -0,0 +1,270 @@
+// Package webhook delivers Chirpy events to endpoints registered by users.
+//
+// Every request is a POST of the JSON Event, signed the same way Chirpy
+// expects inbound webhooks to be signed (see auth.WebhookVerifier), so a
+// receiver can check it with the endpoint's secret. Failed deliveries are
+// retried with exponential backoff until MaxAttempts is reached.
+//
+// Endpoints are registered by users, so the sender refuses to connect to
+// loopback, private and link-local addresses unless the endpoint is
+// trusted. The check runs when the connection is dialed, after DNS, so a
+// host that resolves to a public address at registration and a private one
+// later is still refused.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/frozendolphin/Chirpy/internal/auth"
+	"github.com/google/uuid"
+)
+
+const (
+	ChirpCreated   = "chirp.created"
+	ChirpDeleted   = "chirp.deleted"
+	UserFollowed   = "user.followed"
+	UserUnfollowed = "user.unfollowed"
+)
+
+// EventTypes lists every event an endpoint can subscribe to.
+var EventTypes = []string{ChirpCreated, ChirpDeleted, UserFollowed, UserUnfollowed}
+
+const (
+	SignatureHeader = "X-Chirpy-Signature"
+	EventHeader     = "X-Chirpy-Event"
+	DeliveryHeader  = "X-Chirpy-Delivery"
+)
+
+const (
+	// MaxAttempts is how many times a delivery is tried before it is dead.
+	MaxAttempts = 10
+	baseBackoff = time.Minute
+	maxBackoff  = 12 * time.Hour
+)
+
+// Event is the body of every delivery.
+type Event struct {
+	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// ValidEventType reports whether t is an event endpoints can subscribe to.
+func ValidEventType(t string) bool {
+	return slices.Contains(EventTypes, t)
+}
+
+// ErrPrivateAddress is returned for endpoints on addresses users may not
+// send to.
+var ErrPrivateAddress = errors.New("address is not public")
+
+// blockedPrefixes are ranges PublicAddr refuses on top of the ones netip
+// classifies: shared address space, benchmarking, and the IPv6 prefixes
+// that embed an IPv4 address, which could be a private one.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("2002::/16"),
+}
+
+// PublicAddr reports whether ip is a public unicast address, rather than
+// loopback, private, link-local, unique-local or otherwise special.
+func PublicAddr(ip netip.Addr) bool {
+
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupHost resolves endpoint hosts. Tests replace it.
+var lookupHost = net.DefaultResolver.LookupNetIP
+
+// ValidateURL checks that raw is an absolute http or https URL whose host
+// resolves only to public addresses. Trusted endpoints, which only admins
+// register, may also use plain http and private addresses.
+func ValidateURL(ctx context.Context, raw string, trusted bool) error {
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return errors.New("url is not valid")
+	}
+	if u.Host == "" || u.Hostname() == "" {
+		return errors.New("url must be absolute")
+	}
+	switch {
+	case u.Scheme == "https":
+	case u.Scheme == "http" && trusted:
+	case u.Scheme == "http":
+		return errors.New("url must use https")
+	default:
+		return errors.New("url must use http or https")
+	}
+	if trusted {
+		return nil
+	}
+
+	addrs := []netip.Addr{}
+	if ip, err := netip.ParseAddr(u.Hostname()); err == nil {
+		addrs = append(addrs, ip)
+	} else {
+		addrs, err = lookupHost(ctx, "ip", u.Hostname())
+		if err != nil || len(addrs) == 0 {
+			return fmt.Errorf("couldn't resolve %s", u.Hostname())
+		}
+	}
+	for _, ip := range addrs {
+		if !PublicAddr(ip) {
+			return fmt.Errorf("url must point at a public address: %w", ErrPrivateAddress)
+		}
+	}
+	return nil
+}
+
+// Backoff is how long to wait before the next try after attempt failed
+// (attempts count from 1).
+func Backoff(attempt int) time.Duration {
+
+	if attempt < 1 {
+		attempt = 1
+	}
+	d := baseBackoff
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if d >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return d
+}
+
+// Result is the outcome of a single delivery attempt.
+type Result struct {
+	// StatusCode is 0 when no response came back.
+	StatusCode int
+	Err        error
+	Duration   time.Duration
+}
+
+// OK reports whether the receiver accepted the delivery.
+func (r Result) OK() bool {
+	return r.Err == nil && r.StatusCode >= 200 && r.StatusCode < 300
+}
+
+// Sender posts deliveries to endpoints.
+type Sender struct {
+	// Client sends to untrusted endpoints and only dials public addresses.
+	Client *http.Client
+	// Trusted sends to trusted endpoints and may dial any address.
+	Trusted *http.Client
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// NewSender returns a Sender whose requests give up after timeout.
+func NewSender(timeout time.Duration) *Sender {
+
+	guarded := &net.Dialer{
+		Timeout: timeout,
+		Control: refusePrivate,
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would be dialed instead of the endpoint, skipping the check
+	transport.Proxy = nil
+	transport.DialContext = guarded.DialContext
+
+	return &Sender{
+		Client:  newClient(timeout, transport),
+		Trusted: newClient(timeout, http.DefaultTransport),
+	}
+}
+
+func newClient(timeout time.Duration, transport http.RoundTripper) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		// a redirect would resend the payload somewhere the owner didn't register
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// refusePrivate is a net.Dialer Control hook. It sees the address actually
+// being dialed, after DNS, so rebinding a host to a private address fails.
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !PublicAddr(ip) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// Send posts payload to endpoint, signed with secret. Only trusted
+// endpoints may be on private addresses.
+func (s *Sender) Send(ctx context.Context, endpoint, secret string, trusted bool, delivery_id uuid.UUID, event_type string, payload []byte) Result {
+
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	start := now()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return Result{Err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set(EventHeader, event_type)
+	req.Header.Set(DeliveryHeader, delivery_id.String())
+	req.Header.Set(SignatureHeader, auth.SignWebhook(secret, start, payload))
+
+	client := s.Client
+	if trusted {
+		client = s.Trusted
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return Result{Err: err, Duration: now().Sub(start)}
+	}
+	defer res.Body.Close()
+	// drain a little so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(res.Body, 4<<10))
+
+	result := Result{StatusCode: res.StatusCode, Duration: now().Sub(start)}
+	if !result.OK() {
+		result.Err = fmt.Errorf("endpoint responded with %s", res.Status)
+	}
+	return result
+}
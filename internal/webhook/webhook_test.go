@@ -0,0 +1,229 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/frozendolphin/Chirpy/internal/auth"
+	"github.com/google/uuid"
+)
+
+func TestSendSignsPayload(t *testing.T) {
+
+	payload := []byte(`{"id":"1","type":"chirp.created","data":{}}`)
+	delivery_id := uuid.New()
+
+	verifier := &auth.WebhookVerifier{
+		Keys:      []string{"endpoint-secret"},
+		Header:    SignatureHeader,
+		Tolerance: time.Minute,
+	}
+
+	var verifyErr error
+	var gotEvent, gotDelivery string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		verifyErr = verifier.Verify(r.Header, body)
+		gotEvent = r.Header.Get(EventHeader)
+		gotDelivery = r.Header.Get(DeliveryHeader)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	sender := NewSender(5 * time.Second)
+	result := sender.Send(context.Background(), receiver.URL, "endpoint-secret", true, delivery_id, ChirpCreated, payload)
+
+	if !result.OK() {
+		t.Fatalf("Send() = %+v, want success", result)
+	}
+	if verifyErr != nil {
+		t.Errorf("receiver couldn't verify the signature: %v", verifyErr)
+	}
+	if gotEvent != ChirpCreated {
+		t.Errorf("event header = %q, want %q", gotEvent, ChirpCreated)
+	}
+	if gotDelivery != delivery_id.String() {
+		t.Errorf("delivery header = %q, want %q", gotDelivery, delivery_id)
+	}
+}
+
+func TestSendFailures(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{
+			name: "server error",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+		},
+		{
+			name: "redirect is not followed",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "/elsewhere", http.StatusFound)
+			},
+		},
+		{
+			name: "too slow",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				time.Sleep(200 * time.Millisecond)
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			receiver := httptest.NewServer(tc.handler)
+			defer receiver.Close()
+
+			sender := NewSender(100 * time.Millisecond)
+			result := sender.Send(context.Background(), receiver.URL, "secret", true, uuid.New(), ChirpDeleted, []byte(`{}`))
+			if result.OK() {
+				t.Fatalf("Send() succeeded, want failure")
+			}
+			if result.Err == nil {
+				t.Errorf("Send() returned no error")
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, time.Minute},
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{4, 8 * time.Minute},
+		{10, 512 * time.Minute},
+		{11, 12 * time.Hour},
+		{50, 12 * time.Hour},
+	}
+
+	for _, tc := range tests {
+		got := Backoff(tc.attempt)
+		if got != tc.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tc.attempt, got, tc.want)
+		}
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+
+	hosts := map[string][]netip.Addr{
+		"hooks.example.com": {netip.MustParseAddr("93.184.216.34")},
+		"rebind.example.com": {
+			netip.MustParseAddr("93.184.216.34"),
+			netip.MustParseAddr("10.0.0.7"),
+		},
+		"metadata.example.com": {netip.MustParseAddr("169.254.169.254")},
+	}
+	lookupHost = func(_ context.Context, _, host string) ([]netip.Addr, error) {
+		addrs, ok := hosts[host]
+		if !ok {
+			return nil, errors.New("no such host")
+		}
+		return addrs, nil
+	}
+	defer func() { lookupHost = net.DefaultResolver.LookupNetIP }()
+
+	tests := []struct {
+		raw     string
+		trusted bool
+		wantErr bool
+	}{
+		{"https://hooks.example.com/chirpy", false, false},
+		{"http://hooks.internal/chirpy", true, false},
+		{"https://10.1.2.3/chirpy", true, false},
+		{"http://hooks.example.com/chirpy", false, true},
+		{"ftp://hooks.example.com", true, true},
+		{"/relative/path", true, true},
+		{"https://", false, true},
+		{"https://unknown.example.com/chirpy", false, true},
+		{"https://169.254.169.254/latest/meta-data", false, true},
+		{"https://10.1.2.3/chirpy", false, true},
+		{"https://127.0.0.1:5432", false, true},
+		{"https://[::1]/chirpy", false, true},
+		{"https://[fd00::1]/chirpy", false, true},
+		{"https://[::ffff:192.168.0.1]/chirpy", false, true},
+		{"https://metadata.example.com/", false, true},
+		{"https://rebind.example.com/chirpy", false, true},
+	}
+
+	for _, tc := range tests {
+		err := ValidateURL(context.Background(), tc.raw, tc.trusted)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("ValidateURL(%q, %v) error = %v, wantErr %v", tc.raw, tc.trusted, err, tc.wantErr)
+		}
+	}
+}
+
+func TestPublicAddr(t *testing.T) {
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1::1", true},
+		{"127.0.0.1", false},
+		{"10.0.0.1", false},
+		{"172.16.5.4", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"224.0.0.1", false},
+		{"::1", false},
+		{"fe80::1", false},
+		{"fc00::1", false},
+		{"::ffff:10.0.0.1", false},
+		{"64:ff9b::a00:1", false},
+	}
+
+	for _, tc := range tests {
+		got := PublicAddr(netip.MustParseAddr(tc.ip))
+		if got != tc.want {
+			t.Errorf("PublicAddr(%s) = %v, want %v", tc.ip, got, tc.want)
+		}
+	}
+}
+
+func TestSendRefusesPrivateAddresses(t *testing.T) {
+
+	hit := false
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hit = true
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	// the receiver listens on loopback, as a rebound host would resolve
+	sender := NewSender(5 * time.Second)
+	result := sender.Send(context.Background(), receiver.URL, "secret", false, uuid.New(), ChirpCreated, []byte(`{}`))
+
+	if result.OK() {
+		t.Fatalf("Send() succeeded, want the dial refused")
+	}
+	if !errors.Is(result.Err, ErrPrivateAddress) {
+		t.Errorf("Send() error = %v, want %v", result.Err, ErrPrivateAddress)
+	}
+	if result.StatusCode != 0 {
+		t.Errorf("StatusCode = %d, want 0", result.StatusCode)
+	}
+	if hit {
+		t.Errorf("the private receiver was reached")
+	}
+}
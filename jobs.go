@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/frozendolphin/Chirpy/internal/database"
+	"github.com/frozendolphin/Chirpy/internal/jobs"
+	"github.com/google/uuid"
+)
+
+// mediaQueue holds jobs that talk to the blob store, kept apart so a slow
+// store can't hold up everything else.
+const mediaQueue = "media"
+
+type publishChirpArgs struct {
+	ChirpID uuid.UUID `json:"chirp_id"`
+}
+
+func (publishChirpArgs) Kind() string { return "publish_chirp" }
+
+type publishDueChirpsArgs struct{}
+
+func (publishDueChirpsArgs) Kind() string { return "publish_due_chirps" }
+
+type deleteBlobsArgs struct {
+	Keys []string `json:"keys"`
+}
+
+func (deleteBlobsArgs) Kind() string { return "delete_blobs" }
+
+// enqueueMediaCleanup queues deleting the files behind media rows that are
+// being deleted in the same transaction.
+func enqueueMediaCleanup(ctx context.Context, q *database.Queries, media []database.Medium) error {
+
+	if len(media) == 0 {
+		return nil
+	}
+	keys := []string{}
+	for _, m := range media {
+		keys = append(keys, m.StorageKey, m.ThumbnailKey)
+	}
+	return enqueueJob(ctx, q, deleteBlobsArgs{Keys: keys}, jobs.Options{Queue: mediaQueue})
+}
+
+// newJobRunner sets up the queues, handlers and periodic jobs.
+func (cfg *apiConfig) newJobRunner() *jobs.Runner {
+
+	runner := jobs.NewRunner(jobStore{db: cfg.db})
+	runner.Queue(mediaQueue, 4)
+
+	jobs.Register(runner, cfg.publishChirpJob)
+	jobs.Register(runner, cfg.publishDueChirpsJob)
+	jobs.Register(runner, cfg.deleteBlobsJob)
+	jobs.Register(runner, cfg.pruneExpiredJob)
+
+	runner.Periodic(time.Minute, publishDueChirpsArgs{}, jobs.Options{MaxAttempts: 1})
+	runner.Periodic(cfg.retention.Interval, pruneExpiredArgs{}, jobs.Options{MaxAttempts: 1})
+
+	return runner
+}
+
+// enqueueJob adds a job to the queue. Pass the queries of the transaction
+// that made the change, so the job only exists if the change commits.
+func enqueueJob(ctx context.Context, q *database.Queries, args jobs.Args, opts jobs.Options) error {
+
+	job, err := jobs.Prepare(args, opts)
+	if err != nil {
+		return err
+	}
+
+	_, err = jobStore{db: q}.Insert(ctx, job)
+	return err
+}
+
+func (cfg *apiConfig) deleteBlobsJob(ctx context.Context, args deleteBlobsArgs) error {
+
+	// deleting a missing blob succeeds, so a retry can start over
+	for _, key := range args.Keys {
+		err := cfg.blobs.Delete(ctx, key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jobStore keeps the job queue in the jobs table.
+type jobStore struct {
+	db *database.Queries
+}
+
+func (s jobStore) Insert(ctx context.Context, job jobs.NewJob) (bool, error) {
+
+	inserted, err := s.db.InsertJob(ctx, database.InsertJobParams{
+		Queue:       job.Queue,
+		Kind:        job.Kind,
+		Payload:     job.Payload,
+		MaxAttempts: int32(job.MaxAttempts),
+		RunAt:       job.RunAt.UTC(),
+		UniqueKey:   sql.NullString{String: job.UniqueKey, Valid: job.UniqueKey != ""},
+	})
+	return inserted > 0, err
+}
+
+func (s jobStore) Claim(ctx context.Context, queue string, limit int, lease time.Duration) ([]jobs.Job, error) {
+
+	rows, err := s.db.ClaimJobs(ctx, database.ClaimJobsParams{
+		Queue:        queue,
+		Limit:        int32(limit),
+		LeaseSeconds: int32(lease.Seconds()),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := []jobs.Job{}
+	for _, row := range rows {
+		claimed = append(claimed, jobs.Job{
+			ID:          row.ID,
+			Queue:       row.Queue,
+			Kind:        row.Kind,
+			Payload:     row.Payload,
+			Attempt:     int(row.Attempts),
+			MaxAttempts: int(row.MaxAttempts),
+		})
+	}
+	return claimed, nil
+}
+
+func (s jobStore) Complete(ctx context.Context, id uuid.UUID) error {
+	return s.db.CompleteJob(ctx, id)
+}
+
+func (s jobStore) Retry(ctx context.Context, id uuid.UUID, at time.Time, reason string) error {
+	return s.db.RetryJob(ctx, database.RetryJobParams{
+		ID:        id,
+		RunAt:     at.UTC(),
+		LastError: reason,
+	})
+}
+
+func (s jobStore) Fail(ctx context.Context, id uuid.UUID, reason string) error {
+	return s.db.FailJob(ctx, database.FailJobParams{
+		ID:        id,
+		LastError: reason,
+	})
+}
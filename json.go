@@ -2,33 +2,155 @@ package main
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
 	"net/http"
+	"strings"
+
+	"github.com/frozendolphin/Chirpy/internal/problem"
+	"github.com/frozendolphin/Chirpy/internal/validate"
 )
 
+// respondWithError sends a problem response with the generic code for
+// code. Use respondWithProblem when there is a more specific one.
 func respondWithError(w http.ResponseWriter, code int, msg string, err error) {
-	if err != nil {
-		log.Println(err)
-	}
-	if code > 499 {
-		log.Printf("Responding with 5XX error: %s", msg)
+	respondWithProblem(w, problem.New(problem.ForStatus(code), code, msg), err)
+}
+
+func respondWithProblem(w http.ResponseWriter, p *problem.Problem, err error) {
+	// the access log line for the request carries the reason
+	if rec := recorderOf(w); rec != nil {
+		rec.errMsg = p.Detail
+		rec.err = err
+	} else if err != nil || p.Status > 499 {
+		slog.Error("responding with error", "status", p.Status, "code", p.Code, "message", p.Detail, "error", err)
 	}
-	type errorResponse struct {
-		Error string `json:"error"`
+	if write_err := problem.Write(w, p); write_err != nil {
+		slog.Error("couldn't write problem response", "error", write_err)
 	}
-	respondWithJSON(w, code, errorResponse{
-		Error: msg,
-	})
 }
 
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	dat, err := json.Marshal(payload)
 	if err != nil {
-		log.Printf("Error marshalling JSON: %s", err)
+		slog.Error("couldn't marshal JSON response", "error", err)
 		w.WriteHeader(500)
 		return
 	}
 	w.WriteHeader(code)
 	w.Write(dat)
 }
+
+// decodeJSON reads the request body into dst and checks it against dst's
+// validate tags. Bodies must be JSON, a single value, no larger than
+// cfg.maxBodyBytes and without fields dst doesn't have. It writes the
+// problem response itself when it returns false.
+func (cfg *apiConfig) decodeJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
+
+	media_type, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (media_type != "application/json" && !strings.HasSuffix(media_type, "+json")) {
+		respondWithProblem(w, problem.New(problem.CodeUnsupportedMediaType, 0, "request body must be application/json"), err)
+		return false
+	}
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, cfg.maxBodyBytes))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(dst)
+	if err == nil {
+		// anything but the end of the body after the value is a mistake
+		if _, token_err := decoder.Token(); !errors.Is(token_err, io.EOF) {
+			err = errTrailingData
+		}
+	}
+	if err != nil {
+		respondWithProblem(w, decodeProblem(err), err)
+		return false
+	}
+
+	err = validate.Struct(dst)
+	var field_errs validate.Errors
+	if errors.As(err, &field_errs) {
+		respondWithProblem(w, validationProblem(field_errs), nil)
+		return false
+	}
+	return true
+}
+
+var errTrailingData = errors.New("request body must hold a single JSON value")
+
+// validationProblem turns the validate package's errors into a problem.
+func validationProblem(errs validate.Errors) *problem.Problem {
+	field_errs := make([]problem.FieldError, len(errs))
+	for i, e := range errs {
+		field_errs[i] = problem.FieldError{Field: e.Field, Code: e.Code, Message: e.Message}
+	}
+	return problem.Validation(field_errs...)
+}
+
+// decodeProblem says what was wrong with a body json.Decoder refused.
+func decodeProblem(err error) *problem.Problem {
+
+	var syntax_err *json.SyntaxError
+	var type_err *json.UnmarshalTypeError
+	var max_bytes_err *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &max_bytes_err):
+		return problem.New(problem.CodeBodyTooLarge, 0, fmt.Sprintf("request body is over %d bytes", max_bytes_err.Limit))
+	case errors.Is(err, errTrailingData):
+		return problem.New(problem.CodeMalformedJSON, 0, err.Error())
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no error type for this
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return problem.Validation(problem.FieldError{
+			Field:   field,
+			Code:    "unknown_field",
+			Message: "isn't a field of this request",
+		})
+	case errors.Is(err, io.EOF):
+		return problem.New(problem.CodeMalformedJSON, 0, "request body is empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return problem.New(problem.CodeMalformedJSON, 0, "request body ends in the middle of a JSON value")
+	case errors.As(err, &syntax_err):
+		return problem.New(problem.CodeMalformedJSON, 0, fmt.Sprintf("request body isn't valid JSON at byte %d", syntax_err.Offset)).
+			With("offset", syntax_err.Offset)
+	case errors.As(err, &type_err):
+		field := type_err.Field
+		if field == "" {
+			field = "(body)"
+		}
+		return problem.Validation(problem.FieldError{
+			Field:   field,
+			Code:    "invalid_type",
+			Message: "must be " + jsonTypeName(type_err.Type.Kind().String()),
+		})
+	}
+	// a value of the right JSON type that its Go type refused, like a
+	// malformed uuid or timestamp
+	return problem.Validation(problem.FieldError{
+		Field:   "(body)",
+		Code:    "invalid_value",
+		Message: err.Error(),
+	})
+}
+
+// jsonTypeName names a Go kind the way a client writing JSON thinks of it.
+func jsonTypeName(kind string) string {
+	switch {
+	case kind == "string":
+		return "a string"
+	case kind == "bool":
+		return "a boolean"
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"), strings.HasPrefix(kind, "float"):
+		return "a number"
+	case kind == "slice", kind == "array":
+		return "an array"
+	case kind == "struct", kind == "map":
+		return "an object"
+	}
+	return "a " + kind
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/frozendolphin/Chirpy/internal/logging"
+)
+
+// responseRecorder remembers what a handler sent, for the access log.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+	// set by respondWithError
+	errMsg string
+	err    error
+	// set by recordRoute once the mux has matched a pattern
+	route string
+	// set by traceRequests
+	traceID string
+	// set by authenticate and requireRefreshToken
+	userID string
+}
+
+func (rec *responseRecorder) statusCode() int {
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+	return rec.status
+}
+
+// routeLabel is the matched pattern, or "unmatched" for a 404 from the mux.
+func (rec *responseRecorder) routeLabel() string {
+	if rec.route == "" {
+		return "unmatched"
+	}
+	return rec.route
+}
+
+func (rec *responseRecorder) WriteHeader(code int) {
+	if rec.status == 0 {
+		rec.status = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rec *responseRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// recorderOf finds the responseRecorder under w, if any.
+func recorderOf(w http.ResponseWriter) *responseRecorder {
+
+	for {
+		switch v := w.(type) {
+		case *responseRecorder:
+			return v
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = v.Unwrap()
+		default:
+			return nil
+		}
+	}
+}
+
+// recordRoute wraps the mux, which fills in r.Pattern on the request it
+// gets. Middleware further out works on copies of the request made by
+// WithContext, so it reads the pattern from the recorder instead.
+func recordRoute(mux http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.ServeHTTP(w, r)
+		if rec := recorderOf(w); rec != nil {
+			rec.route = r.Pattern
+		}
+	})
+}
+
+// requestLogger gives every request an ID and a logger carrying it, and
+// logs one line per request once it's served.
+func (cfg *apiConfig) requestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		start := time.Now()
+		request_id := logging.RequestID(r.Header)
+		w.Header().Set(logging.RequestIDHeader, request_id)
+
+		logger := slog.Default().With("request_id", request_id)
+		ctx := logging.WithRequestID(r.Context(), request_id)
+		ctx = logging.WithLogger(ctx, logger)
+		r = r.WithContext(ctx)
+
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		attrs := []any{
+			"method", r.Method,
+			"route", rec.routeLabel(),
+			"path", r.URL.Path,
+			"status", rec.statusCode(),
+			"duration_ms", time.Since(start).Milliseconds(),
+			"bytes", rec.bytes,
+			"remote_addr", r.RemoteAddr,
+		}
+		if rec.traceID != "" {
+			attrs = append(attrs, "trace_id", rec.traceID)
+		}
+		if rec.userID != "" {
+			attrs = append(attrs, "user_id", rec.userID)
+		}
+		if rec.errMsg != "" {
+			attrs = append(attrs, "message", rec.errMsg)
+		}
+		if rec.err != nil {
+			attrs = append(attrs, "error", rec.err.Error())
+		}
+		if logger.Enabled(ctx, slog.LevelDebug) {
+			attrs = append(attrs, "headers", logging.Headers(r.Header))
+		}
+
+		level := slog.LevelInfo
+		if rec.statusCode() >= 500 {
+			level = slog.LevelError
+		}
+		logger.Log(ctx, level, "request", attrs...)
+	})
+}
@@ -1,71 +1,324 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"flag"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
 	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"github.com/frozendolphin/Chirpy/internal/auth"
+	"github.com/frozendolphin/Chirpy/internal/blob"
+	"github.com/frozendolphin/Chirpy/internal/config"
 	"github.com/frozendolphin/Chirpy/internal/database"
+	"github.com/frozendolphin/Chirpy/internal/filter"
+	"github.com/frozendolphin/Chirpy/internal/health"
+	"github.com/frozendolphin/Chirpy/internal/jobs"
+	"github.com/frozendolphin/Chirpy/internal/logging"
+	"github.com/frozendolphin/Chirpy/internal/password"
+	"github.com/frozendolphin/Chirpy/internal/tracing"
+	"github.com/frozendolphin/Chirpy/internal/webhook"
 )
 
 type apiConfig struct {
-	fileserverHits atomic.Int32
 	db *database.Queries
+	dbConn *sql.DB
+	blobs blob.Store
+	mediaMaxBytes int64
+	mediaMaxPending int
+	maxBodyBytes int64
+	passwordPolicy password.Policy
+	filter *filter.Filter
+	reportHideThreshold int
 	platform string
 	secret string
-	polka_key string
+	polkaVerifier *auth.WebhookVerifier
+	webhookSender *webhook.Sender
+	retention retentionConfig
+	metrics *metrics
+	limiter *rateLimiter
+	// draining is set once shutdown starts, so health checks fail
+	draining atomic.Bool
+	health *health.Registry
 }
 
 func main() {
 
 	godotenv.Load()
-	dbURL := os.Getenv("DB_URL")
-	userPlatform := os.Getenv("PLATFORM")
-	jwtSecret := os.Getenv("SECRET")
-	polka_key := os.Getenv("POLKA_KEY")
+	conf, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		// the logger isn't set up without a valid config
+		log.Fatalf("invalid configuration:\n%v", err)
+	}
+
+	slog.SetDefault(logging.New(os.Stdout, conf.SlogLevel()))
+	slog.Info("starting", "config", conf)
+
+	flush_traces, err := tracing.Setup(context.Background(), tracing.Config{
+		ServiceName: "chirpy",
+		Exporter:    conf.TraceExporter,
+		File:        conf.TraceFile,
+		Endpoint:    conf.TraceEndpoint,
+		SampleRatio: conf.TraceSampleRatio,
+	})
+	if err != nil {
+		fatal("couldn't set up tracing", err)
+	}
 
-	db, err := sql.Open("postgres", dbURL)
+	db, err := sql.Open("postgres", conf.DBURL)
 	if err != nil {
-		log.Fatalf("couldn't open connection with database: %v", err)
+		fatal("couldn't open connection with database", err)
 	}
 
-	dbQueries := database.New(db)
+	metrics := newMetrics(db)
+	dbQueries := newQueries(db, metrics)
+
+	blobs, err := newBlobStore(conf)
+	if err != nil {
+		fatal("couldn't set up media storage", err)
+	}
+
+	password_policy, err := newPasswordPolicy(conf)
+	if err != nil {
+		fatal("couldn't load the breached password list", err)
+	}
 
 	mux := http.NewServeMux()
 
 	apicfg := apiConfig {
 		db: dbQueries,
-		platform: userPlatform,
-		secret: jwtSecret,
-		polka_key: polka_key,
+		dbConn: db,
+		blobs: blobs,
+		mediaMaxBytes: conf.MediaMaxBytes,
+		mediaMaxPending: conf.MediaMaxPending,
+		maxBodyBytes: conf.MaxBodyBytes,
+		passwordPolicy: password_policy,
+		filter: filter.New(nil),
+		reportHideThreshold: conf.ReportHideThreshold,
+		platform: conf.Platform,
+		secret: conf.Secret,
+		polkaVerifier: newPolkaVerifier(conf.PolkaWebhookSecrets),
+		webhookSender: webhook.NewSender(10*time.Second),
+		retention: retentionConfig{
+			Interval: conf.RetentionInterval,
+			RefreshTokens: conf.RefreshTokenRetention,
+			Jobs: conf.JobRetention,
+			WebhookDeliveries: conf.WebhookDeliveryRetention,
+			UnattachedMedia: conf.UnattachedMediaRetention,
+		},
+		health: &health.Registry{Timeout: conf.HealthCheckTimeout},
+		metrics: metrics,
+		limiter: newRateLimiter(conf, dbQueries),
+	}
+
+	err = apicfg.registerHealthChecks()
+	if err != nil {
+		fatal("couldn't set up health checks", err)
+	}
+
+	err = apicfg.reloadFilter(context.Background())
+	if err != nil {
+		fatal("couldn't load filter rules", err)
 	}
 
 	mux.Handle("/app/", apicfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(".")))))
-	mux.HandleFunc("GET /api/healthz", healthz)
+	mux.HandleFunc("GET /api/healthz", apicfg.healthz)
+	mux.HandleFunc("GET /livez", livez)
+	mux.HandleFunc("GET /readyz", apicfg.readyz)
+	mux.Handle("GET /metrics", metrics.handler())
 	mux.HandleFunc("GET /admin/metrics", apicfg.getHits)
 	mux.HandleFunc("POST /admin/reset", apicfg.resetHits)
-	mux.HandleFunc("POST /api/chirps", apicfg.createChirps)
+	mux.HandleFunc("GET /admin/filter-rules", apicfg.requireAuth(apicfg.listFilterRules))
+	mux.HandleFunc("POST /admin/filter-rules", apicfg.requireAuth(apicfg.createFilterRule))
+	mux.HandleFunc("PUT /admin/filter-rules/{ruleID}", apicfg.requireAuth(apicfg.updateFilterRule))
+	mux.HandleFunc("DELETE /admin/filter-rules/{ruleID}", apicfg.requireAuth(apicfg.deleteFilterRule))
+	mux.HandleFunc("GET /admin/moderation/cases", apicfg.requireAuth(apicfg.listModerationCases))
+	mux.HandleFunc("GET /admin/moderation/cases/{caseID}", apicfg.requireAuth(apicfg.getModerationCase))
+	mux.HandleFunc("POST /admin/moderation/cases/{caseID}/claim", apicfg.requireAuth(apicfg.claimModerationCase))
+	mux.HandleFunc("POST /admin/moderation/cases/{caseID}/resolve", apicfg.requireAuth(apicfg.resolveModerationCase))
+	mux.HandleFunc("GET /admin/users/{userID}/standing", apicfg.requireAuth(apicfg.getUserStanding))
+	mux.HandleFunc("PUT /admin/users/{userID}/standing", apicfg.requireAuth(apicfg.setUserStanding))
+	mux.HandleFunc("PUT /admin/plans/{planID}", apicfg.requireAuth(apicfg.updatePlan))
+	mux.HandleFunc("GET /admin/appeals", apicfg.requireAuth(apicfg.listAppeals))
+	mux.HandleFunc("POST /admin/appeals/{appealID}/review", apicfg.requireAuth(apicfg.reviewAppeal))
+	mux.HandleFunc("POST /api/chirps", apicfg.requireAuth(apicfg.createChirps))
 	mux.HandleFunc("POST /api/users", apicfg.createUsers)
-	mux.HandleFunc("GET /api/chirps", apicfg.getAllChirps)
-	mux.HandleFunc("GET /api/chirps/{chirpID}", apicfg.getAChirp)
+	mux.HandleFunc("GET /api/chirps", apicfg.optionalAuth(apicfg.getAllChirps))
+	mux.HandleFunc("GET /api/chirps/{chirpID}", apicfg.optionalAuth(apicfg.getAChirp))
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", apicfg.requireAuth(apicfg.editChirp))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/poll/votes", apicfg.requireAuth(apicfg.votePoll))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/report", apicfg.requireAuth(apicfg.reportChirp))
+	mux.HandleFunc("GET /api/chirps/scheduled", apicfg.requireAuth(apicfg.getScheduledChirps))
+	mux.HandleFunc("PUT /api/chirps/scheduled/{chirpID}", apicfg.requireAuth(apicfg.updateScheduledChirp))
+	mux.HandleFunc("DELETE /api/chirps/scheduled/{chirpID}", apicfg.requireAuth(apicfg.cancelScheduledChirp))
 	mux.HandleFunc("POST /api/login", apicfg.loginUser)
-	mux.HandleFunc("POST /api/refresh", apicfg.newRefresh)
+	mux.HandleFunc("POST /api/appeals", apicfg.createAppeal)
+	mux.HandleFunc("POST /api/refresh", apicfg.requireRefreshToken(apicfg.newRefresh))
 	mux.HandleFunc("POST /api/revoke", apicfg.revokeRefresh)
-	mux.HandleFunc("PUT /api/users", apicfg.changeEmailPass)
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apicfg.deleteAChirp)
-	mux.HandleFunc("POST /api/polka/webhooks", apicfg.upgradeUserChirpyRed)
+	mux.HandleFunc("PUT /api/users", apicfg.requireAuth(apicfg.changeEmailPass))
+	mux.HandleFunc("PATCH /api/users/me", apicfg.requireAuth(apicfg.updateProfile))
+	mux.HandleFunc("GET /api/users/me/subscription", apicfg.requireAuth(apicfg.getMySubscription))
+	mux.HandleFunc("POST /api/webhooks", apicfg.requireAuth(apicfg.createWebhookEndpoint))
+	mux.HandleFunc("GET /api/webhooks", apicfg.requireAuth(apicfg.listWebhookEndpoints))
+	mux.HandleFunc("DELETE /api/webhooks/{webhookID}", apicfg.requireAuth(apicfg.deleteWebhookEndpoint))
+	mux.HandleFunc("GET /api/webhooks/{webhookID}/deliveries", apicfg.requireAuth(apicfg.listWebhookDeliveries))
+	mux.HandleFunc("POST /api/webhooks/{webhookID}/deliveries/{deliveryID}/redeliver", apicfg.requireAuth(apicfg.redeliverWebhook))
+	mux.HandleFunc("GET /api/users/{handle}", apicfg.getUserProfile)
+	mux.HandleFunc("POST /api/users/{handle}/follow", apicfg.requireAuth(apicfg.followUser))
+	mux.HandleFunc("DELETE /api/users/{handle}/follow", apicfg.requireAuth(apicfg.unfollowUser))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apicfg.requireAuth(apicfg.deleteAChirp))
+	mux.HandleFunc("POST /api/polka/webhooks", apicfg.polkaWebhook)
+	mux.HandleFunc("GET /api/plans", apicfg.listPlans)
+	mux.HandleFunc("GET /problems", listProblems)
+	mux.HandleFunc("GET /problems/{code}", getProblem)
+	mux.HandleFunc("POST /api/media", apicfg.requireAuth(apicfg.uploadMedia))
+	mux.HandleFunc("GET /api/media/{mediaID}", apicfg.optionalAuth(apicfg.getMediaOriginal))
+	mux.HandleFunc("GET /api/media/{mediaID}/thumbnail", apicfg.optionalAuth(apicfg.getMediaThumbnail))
 	
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	runner := apicfg.newJobRunner()
+	runner.Start(ctx)
+
+	// the loops below outlive ctx: they keep going while requests drain
+	workers_ctx, stop_workers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	workers.Add(2)
+	go func() {
+		defer workers.Done()
+		apicfg.runFilterReloader(workers_ctx, 30*time.Second)
+	}()
+	go func() {
+		defer workers.Done()
+		apicfg.runWebhookDispatcher(workers_ctx, 5*time.Second)
+	}()
+
 	server_struct := http.Server {
-		Handler: mux,
-		Addr: ":8080",
+		Handler: apicfg.requestLogger(traceRequests(apicfg.authenticate(metrics.instrument(apicfg.rateLimit(mux, recordRoute(mux)))))),
+		Addr: conf.Addr,
+		ReadHeaderTimeout: conf.ReadHeaderTimeout,
+		ReadTimeout: conf.ReadTimeout,
+		WriteTimeout: conf.WriteTimeout,
+		IdleTimeout: conf.IdleTimeout,
+		MaxHeaderBytes: conf.MaxHeaderBytes,
 	}
 
-	err = server_struct.ListenAndServe()
+	go func() {
+		err := server_struct.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("server stopped", err)
+		}
+	}()
+
+	<-ctx.Done()
+	// a second signal kills the process straight away
+	stop()
+
+	apicfg.shutdown(&server_struct, conf, func() {
+		stop_workers()
+		workers.Wait()
+	}, runner, flush_traces)
+}
+
+// shutdown stops taking new work, then lets what is running finish within
+// shutdown_timeout, in order: requests, background loops, jobs. The
+// database goes next since all of them use it, then buffered spans are
+// flushed.
+func (cfg *apiConfig) shutdown(server *http.Server, conf config.Config, stop_workers func(), runner *jobs.Runner, flush_traces func(context.Context) error) {
+
+	cfg.draining.Store(true)
+	if conf.DrainDelay > 0 {
+		slog.Info("draining", "refusing_connections_in", conf.DrainDelay.String())
+		time.Sleep(conf.DrainDelay)
+	}
+
+	drain_ctx, cancel := context.WithTimeout(context.Background(), conf.ShutdownTimeout)
+	defer cancel()
+
+	slog.Info("shutting down, waiting for in-flight requests")
+	err := server.Shutdown(drain_ctx)
+	if err != nil {
+		slog.Warn("requests didn't finish in time", "error", err)
+		server.Close()
+	}
+
+	stop_workers()
+
+	// jobs cut off are retried once their lease runs out
+	slog.Info("waiting for running jobs")
+	err = runner.Shutdown(drain_ctx)
+	if err != nil {
+		slog.Warn("jobs didn't finish in time", "error", err)
+	}
+
+	err = cfg.dbConn.Close()
 	if err != nil {
-		log.Fatalf("err occured: %v", err)
+		slog.Error("couldn't close database pool", "error", err)
 	}
-}
\ No newline at end of file
+
+	// the drain may have used up the deadline; spans still deserve a try
+	flush_ctx, cancel_flush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel_flush()
+	err = flush_traces(flush_ctx)
+	if err != nil {
+		slog.Error("couldn't flush traces", "error", err)
+	}
+	slog.Info("shutdown complete")
+}
+
+// withTx returns queries that run in tx.
+func (cfg *apiConfig) withTx(tx *sql.Tx) *database.Queries {
+	return newQueries(tx, cfg.metrics)
+}
+
+// fatal logs err and exits.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// newBlobStore picks the media storage backend from media_store.
+func newBlobStore(conf config.Config) (blob.Store, error) {
+
+	switch conf.MediaStore {
+	case "s3":
+		return blob.NewS3Store(blob.S3Config{
+			Endpoint:  conf.S3Endpoint,
+			Bucket:    conf.S3Bucket,
+			Region:    conf.S3Region,
+			AccessKey: conf.S3AccessKey,
+			SecretKey: conf.S3SecretKey,
+		})
+	default:
+		return blob.NewLocalStore(conf.MediaDir)
+	}
+}
+
+// newPolkaVerifier accepts any of keys, so an old and a new key both work
+// while they are rotated.
+func newPolkaVerifier(keys []string) *auth.WebhookVerifier {
+
+	if len(keys) == 0 {
+		slog.Warn("no Polka webhook secrets are set; Polka webhooks will be refused")
+	}
+
+	return &auth.WebhookVerifier{
+		Keys:      keys,
+		Header:    "X-Polka-Signature",
+		Tolerance: 5 * time.Minute,
+	}
+}
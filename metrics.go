@@ -1,10 +1,126 @@
 package main
 
 import (
+	"database/sql"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
 )
 
+// metrics holds every Prometheus collector, served at /metrics. They are
+// kept in their own registry rather than the global one.
+type metrics struct {
+	registry *prometheus.Registry
+
+	requestDuration  *prometheus.HistogramVec
+	requestsInFlight prometheus.Gauge
+	fileserverHits   prometheus.Counter
+	queryDuration    *prometheus.HistogramVec
+	queryErrors      *prometheus.CounterVec
+	logins           *prometheus.CounterVec
+	polkaWebhooks    *prometheus.CounterVec
+	webhookAttempts  *prometheus.CounterVec
+	rateLimited      *prometheus.CounterVec
+	prunedRows       *prometheus.CounterVec
+	lastPrune        prometheus.Gauge
+}
+
+func newMetrics(db *sql.DB) *metrics {
+
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chirpy_http_request_duration_seconds",
+			Help:    "Time to serve HTTP requests; the _count series counts requests.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		requestsInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "chirpy_http_requests_in_flight",
+			Help: "Requests being served.",
+		}),
+		fileserverHits: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "chirpy_fileserver_hits_total",
+			Help: "Requests for static files under /app/.",
+		}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chirpy_db_query_duration_seconds",
+			Help:    "Time to run database queries, by sqlc query name.",
+			Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"query"}),
+		queryErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_db_query_errors_total",
+			Help: "Database queries that failed, by sqlc query name.",
+		}, []string{"query"}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_logins_total",
+			Help: "Login attempts by outcome: success, failure or restricted.",
+		}, []string{"outcome"}),
+		polkaWebhooks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_polka_webhooks_total",
+			Help: "Inbound Polka webhooks by outcome.",
+		}, []string{"outcome"}),
+		webhookAttempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_webhook_delivery_attempts_total",
+			Help: "Outbound webhook delivery attempts by outcome: delivered, retry or dead.",
+		}, []string{"outcome"}),
+		rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_rate_limited_total",
+			Help: "Requests refused with 429 by rate limit policy.",
+		}, []string{"policy"}),
+		prunedRows: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_retention_pruned_rows_total",
+			Help: "Rows deleted for being past their retention, by table.",
+		}, []string{"table"}),
+		lastPrune: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "chirpy_retention_last_run_timestamp_seconds",
+			Help: "When pruning last finished, as a Unix time.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		m.requestDuration, m.requestsInFlight, m.fileserverHits,
+		m.queryDuration, m.queryErrors,
+		m.logins, m.polkaWebhooks, m.webhookAttempts, m.rateLimited,
+		m.prunedRows, m.lastPrune,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewDBStatsCollector(db, "chirpy"),
+	)
+	return m
+}
+
+// handler serves the registry in the Prometheus text format.
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// instrument records the duration of every request by route pattern and
+// status. It relies on requestLogger, further out, for the recorder and on
+// recordRoute, further in, for the route.
+func (m *metrics) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		m.requestsInFlight.Inc()
+		defer m.requestsInFlight.Dec()
+
+		start := time.Now()
+		next.ServeHTTP(w, r)
+
+		status, route := http.StatusOK, "unmatched"
+		if rec := recorderOf(w); rec != nil {
+			status, route = rec.statusCode(), rec.routeLabel()
+		}
+		// the pattern, not the path, so IDs don't make a series each
+		m.requestDuration.WithLabelValues(r.Method, route, strconv.Itoa(status)).Observe(time.Since(start).Seconds())
+	})
+}
+
 func (cfg *apiConfig) getHits(w http.ResponseWriter, req *http.Request){
 	w.Header().Set("Content-Type", "text/html")
 	w.WriteHeader(http.StatusOK)
@@ -12,14 +128,40 @@ func (cfg *apiConfig) getHits(w http.ResponseWriter, req *http.Request){
   <body>
     <h1>Welcome, Chirpy Admin</h1>
     <p>Chirpy has been visited %d times!</p>
+    <h2>Retention</h2>
+    <p>Pruned since start: %d refresh tokens, %d jobs, %d webhook deliveries</p>
+    <p>Last run: %s</p>
   </body>
-</html>`, cfg.fileserverHits.Load())
+</html>`, int64(counterValue(cfg.metrics.fileserverHits)),
+		int64(counterValue(cfg.metrics.prunedRows.WithLabelValues(prunedRefreshTokens))),
+		int64(counterValue(cfg.metrics.prunedRows.WithLabelValues(prunedJobs))),
+		int64(counterValue(cfg.metrics.prunedRows.WithLabelValues(prunedWebhookDeliveries))),
+		lastPruneRun(cfg.metrics.lastPrune))
 	w.Write([]byte(hits))
 }
 
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-        cfg.fileserverHits.Add(1) 
+        cfg.metrics.fileserverHits.Inc()
         next.ServeHTTP(w, r)      
     })
-}
\ No newline at end of file
+}
+
+// counterValue reads a counter's current value for the admin page.
+func counterValue(c prometheus.Counter) float64 {
+
+	var m dto.Metric
+	if c.Write(&m) != nil {
+		return 0
+	}
+	return m.GetCounter().GetValue()
+}
+
+func lastPruneRun(g prometheus.Gauge) string {
+
+	var m dto.Metric
+	if g.Write(&m) != nil || m.GetGauge().GetValue() == 0 {
+		return "not yet"
+	}
+	return time.Unix(int64(m.GetGauge().GetValue()), 0).UTC().Format(time.RFC3339)
+}
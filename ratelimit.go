@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/frozendolphin/Chirpy/internal/auth"
+	"github.com/frozendolphin/Chirpy/internal/config"
+	"github.com/frozendolphin/Chirpy/internal/database"
+	"github.com/frozendolphin/Chirpy/internal/logging"
+	"github.com/frozendolphin/Chirpy/internal/ratelimit"
+	"github.com/google/uuid"
+)
+
+// rateLimitPolicy limits a group of routes. Each client gets its own
+// bucket per policy.
+type rateLimitPolicy struct {
+	name  string
+	limit ratelimit.Limit
+	// redLimit replaces limit for Chirpy Red users, when set
+	redLimit ratelimit.Limit
+	// byIP ignores the access token, for routes used before signing in
+	byIP bool
+}
+
+type rateLimiter struct {
+	store ratelimit.Store
+	// routes maps mux patterns to their policy; others get fallback
+	routes   map[string]rateLimitPolicy
+	fallback rateLimitPolicy
+	// trustForwardedFor takes the client IP from X-Forwarded-For
+	trustForwardedFor bool
+}
+
+// rateLimitExempt are probed by infrastructure, often from one address.
+var rateLimitExempt = map[string]bool{
+	"GET /api/healthz": true,
+	"GET /livez":       true,
+	"GET /readyz":      true,
+	"GET /metrics":     true,
+	"/app/":            true,
+}
+
+func newRateLimiter(conf config.Config, db *database.Queries) *rateLimiter {
+
+	var store ratelimit.Store = ratelimit.NewMemoryStore()
+	if conf.RateLimitStore == "postgres" {
+		store = pgRateLimitStore{db: db}
+	}
+
+	return &rateLimiter{
+		store: store,
+		routes: map[string]rateLimitPolicy{
+			"POST /api/login":  {name: "login", limit: conf.RateLimitLogin, byIP: true},
+			"POST /api/users":  {name: "signup", limit: conf.RateLimitSignup, byIP: true},
+			"POST /api/chirps": {name: "chirps", limit: conf.RateLimitChirps, redLimit: conf.RateLimitChirpsRed},
+		},
+		fallback:          rateLimitPolicy{name: "default", limit: conf.RateLimitDefault},
+		trustForwardedFor: conf.TrustForwardedFor,
+	}
+}
+
+// rateLimit refuses requests over their route's policy with 429 and tells
+// clients where they stand with RateLimit-* headers. It looks up the route
+// itself, as it runs before the mux.
+func (cfg *apiConfig) rateLimit(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		_, pattern := mux.Handler(r)
+		if rateLimitExempt[pattern] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		policy, ok := cfg.limiter.routes[pattern]
+		if !ok {
+			policy = cfg.limiter.fallback
+		}
+
+		key := "ip:" + cfg.limiter.clientIP(r)
+		limit := policy.limit
+		if !policy.byIP {
+			if principal, ok := auth.PrincipalFrom(r.Context()); ok {
+				key = "user:" + principal.UserID.String()
+				limit = cfg.userLimit(r.Context(), policy, principal.UserID)
+			}
+		}
+		if limit.Unlimited() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		res, err := cfg.limiter.store.Allow(r.Context(), policy.name+":"+key, limit)
+		if err != nil {
+			// an outage of the store shouldn't take the API down with it
+			logging.FromContext(r.Context()).Warn("couldn't check rate limit, allowing request", "policy", policy.name, "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		setRateLimitHeaders(w.Header(), limit, res)
+		if !res.Allowed {
+			cfg.metrics.rateLimited.WithLabelValues(policy.name).Inc()
+			if rec := recorderOf(w); rec != nil {
+				rec.route = pattern
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			respondWithError(w, http.StatusTooManyRequests, "Too many requests, try again later", nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// userLimit gives Chirpy Red users their policy's higher limit.
+func (cfg *apiConfig) userLimit(ctx context.Context, policy rateLimitPolicy, user_id uuid.UUID) ratelimit.Limit {
+
+	if policy.redLimit == (ratelimit.Limit{}) {
+		return policy.limit
+	}
+
+	is_red, err := cfg.isChirpyRed(ctx, user_id)
+	if err != nil || !is_red {
+		return policy.limit
+	}
+	return policy.redLimit
+}
+
+// clientIP is the address limits are keyed on. Behind a proxy, the last
+// X-Forwarded-For entry is the one the proxy added; earlier ones come from
+// the client and can be forged.
+func (l *rateLimiter) clientIP(r *http.Request) string {
+
+	if l.trustForwardedFor {
+		forwarded := r.Header.Values("X-Forwarded-For")
+		if len(forwarded) > 0 {
+			hops := strings.Split(forwarded[len(forwarded)-1], ",")
+			if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// setRateLimitHeaders follows the IETF RateLimit header fields draft.
+func setRateLimitHeaders(h http.Header, limit ratelimit.Limit, res ratelimit.Result) {
+	h.Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+	h.Set("RateLimit-Policy", strconv.Itoa(limit.Requests)+";w="+strconv.Itoa(ceilSeconds(limit.Per)))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// pgRateLimitStore keeps buckets in the rate_limits table, so every
+// replica sees the same ones.
+type pgRateLimitStore struct {
+	db *database.Queries
+}
+
+func (s pgRateLimitStore) Allow(ctx context.Context, key string, l ratelimit.Limit) (ratelimit.Result, error) {
+
+	row, err := s.db.TakeRateLimit(ctx, database.TakeRateLimitParams{
+		Key:            key,
+		IntervalMicros: l.Interval().Microseconds(),
+		PerMicros:      l.Per.Microseconds(),
+	})
+	if err == nil {
+		return ratelimit.Taken(row.Tat, row.Now, l), nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return ratelimit.Result{}, err
+	}
+
+	// refused; read the bucket to say when to come back
+	current, err := s.db.GetRateLimit(ctx, key)
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+	_, res := ratelimit.Decide(current.Tat, current.Now, l)
+	res.Allowed = false
+	return res, nil
+}
@@ -1,9 +1,126 @@
 package main
 
-import "net/http"
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
 
-func healthz(w http.ResponseWriter, req *http.Request){
+	"github.com/frozendolphin/Chirpy/internal/health"
+)
+
+// schemaFiles are the goose migrations this binary was built with.
+//
+//go:embed sql/schema/*.sql
+var schemaFiles embed.FS
+
+func (cfg *apiConfig) healthz(w http.ResponseWriter, req *http.Request){
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	// tell load balancers to stop routing here while requests drain
+	if cfg.draining.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte("draining"))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
+}
+
+// livez says the process is up. It checks nothing else: restarting a
+// replica doesn't bring a database back.
+func livez(w http.ResponseWriter, req *http.Request) {
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("OK"))
 }
+
+// readyz says whether this replica should get traffic, with the status and
+// timing of every registered check. It needs no authentication, so why a
+// check failed only goes to the log.
+func (cfg *apiConfig) readyz(w http.ResponseWriter, req *http.Request) {
+
+	if cfg.draining.Load() {
+		respondWithJSON(w, http.StatusServiceUnavailable, health.Report{
+			Status: "draining",
+			Checks: map[string]health.Result{},
+		})
+		return
+	}
+
+	report := cfg.health.Run(req.Context())
+	if !report.OK() {
+		for name, res := range report.Checks {
+			if res.Status != health.StatusOK {
+				slog.Error("readiness check failed", "check", name, "error", res.Error, "duration_ms", res.DurationMs)
+			}
+		}
+		respondWithJSON(w, http.StatusServiceUnavailable, report.Public())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, report.Public())
+}
+
+// registerHealthChecks adds the checks every replica needs.
+func (cfg *apiConfig) registerHealthChecks() error {
+
+	want, err := schemaVersion(schemaFiles)
+	if err != nil {
+		return err
+	}
+
+	cfg.health.Register("database", health.CheckerFunc(func(ctx context.Context) error {
+		return cfg.dbConn.PingContext(ctx)
+	}))
+	cfg.health.Register("migrations", health.CheckerFunc(func(ctx context.Context) error {
+		return cfg.checkMigrations(ctx, want)
+	}))
+	return nil
+}
+
+// checkMigrations fails if the database is behind the migrations this
+// binary was built with. A database ahead of it passes, since during a
+// rolling deploy the new migrations run before old replicas are replaced.
+// That only works if each migration leaves the schema usable by the previous
+// release: a column or constraint the old code still relies on has to be
+// dropped a release after the code stops using it.
+func (cfg *apiConfig) checkMigrations(ctx context.Context, want int64) error {
+
+	var got int64
+	err := cfg.dbConn.QueryRowContext(ctx,
+		"SELECT COALESCE(MAX(version_id), 0) FROM goose_db_version WHERE is_applied",
+	).Scan(&got)
+	if err != nil {
+		return fmt.Errorf("couldn't read migration version: %w", err)
+	}
+
+	if got < want {
+		return fmt.Errorf("database is at migration %d, want %d", got, want)
+	}
+	return nil
+}
+
+// schemaVersion is the highest migration number in files, taken from names
+// like 007_chirp_visibility.sql.
+func schemaVersion(files fs.FS) (int64, error) {
+
+	paths, err := fs.Glob(files, "sql/schema/*.sql")
+	if err != nil {
+		return 0, err
+	}
+
+	version := int64(0)
+	for _, path := range paths {
+		name := path[strings.LastIndex(path, "/")+1:]
+		prefix, _, _ := strings.Cut(name, "_")
+		n, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("migration %s isn't numbered", name)
+		}
+		version = max(version, n)
+	}
+	return version, nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/frozendolphin/Chirpy/internal/database"
+	"github.com/frozendolphin/Chirpy/internal/logging"
+)
+
+const (
+	retentionBatchSize = 1000
+	// retentionLockKey is the Postgres advisory lock held while pruning
+	retentionLockKey int64 = 0x43686972707901
+)
+
+// retentionConfig says how long rows are kept once they have no further use.
+type retentionConfig struct {
+	// Interval is how often pruning runs.
+	Interval time.Duration
+	// RefreshTokens are kept this long after they expire or are revoked.
+	RefreshTokens time.Duration
+	// Jobs are kept this long after they complete or fail for good.
+	Jobs time.Duration
+	// WebhookDeliveries are kept this long after they were queued, once
+	// they are delivered or dead.
+	WebhookDeliveries time.Duration
+	// UnattachedMedia are uploads never attached to a chirp, deleted this
+	// long after they were uploaded.
+	UnattachedMedia time.Duration
+}
+
+// tables pruned, as labelled in chirpy_retention_pruned_rows_total
+const (
+	prunedRefreshTokens     = "refresh_tokens"
+	prunedJobs              = "jobs"
+	prunedWebhookDeliveries = "webhook_deliveries"
+	prunedRateLimits        = "rate_limits"
+	prunedMedia             = "media"
+)
+
+type pruneExpiredArgs struct{}
+
+func (pruneExpiredArgs) Kind() string { return "prune_expired" }
+
+// pruneExpiredJob deletes rows past their retention in small batches, so
+// no statement holds locks for long. The periodic job already runs once per
+// interval across replicas; the advisory lock also keeps a slow run from
+// overlapping the next one.
+func (cfg *apiConfig) pruneExpiredJob(ctx context.Context, _ pruneExpiredArgs) error {
+
+	// session advisory locks belong to a connection, so hold on to one
+	conn, err := cfg.dbConn.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	q := newQueries(conn, cfg.metrics)
+
+	locked, err := q.TryAdvisoryLock(ctx, retentionLockKey)
+	if err != nil {
+		return err
+	}
+	if !locked {
+		logging.FromContext(ctx).Info("retention: another run is in progress, skipping")
+		return nil
+	}
+	defer q.AdvisoryUnlock(context.WithoutCancel(ctx), retentionLockKey)
+
+	now := time.Now().UTC()
+	tasks := []struct {
+		table  string
+		prune  func(before time.Time) (int64, error)
+		before time.Time
+	}{
+		{
+			table:  prunedRefreshTokens,
+			before: now.Add(-cfg.retention.RefreshTokens),
+			prune: func(before time.Time) (int64, error) {
+				return q.PruneRefreshTokens(ctx, database.PruneRefreshTokensParams{ExpiresAt: before, Limit: retentionBatchSize})
+			},
+		},
+		{
+			table:  prunedJobs,
+			before: now.Add(-cfg.retention.Jobs),
+			prune: func(before time.Time) (int64, error) {
+				return q.PruneJobs(ctx, database.PruneJobsParams{FinishedAt: before, Limit: retentionBatchSize})
+			},
+		},
+		{
+			table:  prunedWebhookDeliveries,
+			before: now.Add(-cfg.retention.WebhookDeliveries),
+			prune: func(before time.Time) (int64, error) {
+				return q.PruneWebhookDeliveries(ctx, database.PruneWebhookDeliveriesParams{CreatedAt: before, Limit: retentionBatchSize})
+			},
+		},
+		{
+			table:  prunedMedia,
+			before: now.Add(-cfg.retention.UnattachedMedia),
+			prune: func(before time.Time) (int64, error) {
+				return cfg.pruneUnattachedMedia(ctx, conn, before)
+			},
+		},
+		{
+			// a bucket that has refilled is the same as no bucket; the
+			// query checks that against the clock the limiter itself uses
+			table: prunedRateLimits,
+			prune: func(time.Time) (int64, error) {
+				return q.PruneRateLimits(ctx, retentionBatchSize)
+			},
+		},
+	}
+
+	for _, task := range tasks {
+		total := int64(0)
+		for {
+			deleted, err := task.prune(task.before)
+			if err != nil {
+				return err
+			}
+			total += deleted
+			cfg.metrics.prunedRows.WithLabelValues(task.table).Add(float64(deleted))
+
+			if deleted < retentionBatchSize {
+				break
+			}
+		}
+		if total > 0 {
+			logging.FromContext(ctx).Info("retention: pruned rows", "table", task.table, "rows", total)
+		}
+	}
+
+	cfg.metrics.lastPrune.SetToCurrentTime()
+	return nil
+}
+
+// pruneUnattachedMedia deletes one batch of uploads that were never
+// attached to a chirp, and queues deleting their files in the same
+// transaction so none are left behind.
+func (cfg *apiConfig) pruneUnattachedMedia(ctx context.Context, conn *sql.Conn, before time.Time) (int64, error) {
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	media, err := qtx.PruneUnattachedMedia(ctx, database.PruneUnattachedMediaParams{CreatedAt: before, Limit: retentionBatchSize})
+	if err != nil {
+		return 0, err
+	}
+
+	err = enqueueMediaCleanup(ctx, qtx, media)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(media)), tx.Commit()
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/frozendolphin/Chirpy/internal/auth"
+	"github.com/frozendolphin/Chirpy/internal/database"
+)
+
+// requireRole checks that the authenticated user is in good standing and
+// holds one of roles, using the user loaded by authenticate. It writes the
+// error response itself when ok is false.
+func (cfg *apiConfig) requireRole(w http.ResponseWriter, r *http.Request, roles ...string) (database.User, bool) {
+
+	principal, ok := auth.PrincipalFrom(r.Context())
+	user, loaded := authUserOf(r)
+	if !ok || !loaded || principal.TokenType != auth.TokenAccess {
+		respondUnauthorized(w, r, "access token required")
+		return database.User{}, false
+	}
+
+	standing := standingOf(user, time.Now().UTC())
+	if standing.restricted() {
+		respondRestricted(w, standing)
+		return database.User{}, false
+	}
+
+	if !principal.HasRole(roles...) {
+		respondWithError(w, http.StatusForbidden, "403 Forbidden: Access Denied", nil)
+		return database.User{}, false
+	}
+
+	return user, true
+}
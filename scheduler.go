@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/frozendolphin/Chirpy/internal/database"
+	"github.com/frozendolphin/Chirpy/internal/logging"
+	"github.com/frozendolphin/Chirpy/internal/webhook"
+)
+
+const scheduledChirpBatchSize = 100
+
+// publishChirpJob publishes a scheduled chirp when its publish_at comes.
+// If the chirp was rescheduled, made a draft or deleted after the job was
+// queued there is nothing to do; a reschedule queues a job of its own.
+func (cfg *apiConfig) publishChirpJob(ctx context.Context, args publishChirpArgs) error {
+
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	chirp, err := qtx.PublishScheduledChirp(ctx, args.ChirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = enqueueEvent(ctx, qtx, webhook.ChirpCreated, newChirpEvent(chirp), chirp.UserID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// publishDueChirpsJob sweeps up scheduled chirps that are past due without
+// a publish_chirp job, such as ones scheduled before jobs existed.
+// PublishDueChirps claims rows with FOR UPDATE SKIP LOCKED, so it never
+// publishes a chirp twice alongside publishChirpJob.
+func (cfg *apiConfig) publishDueChirpsJob(ctx context.Context, _ publishDueChirpsArgs) error {
+
+	for {
+		published, err := cfg.publishChirpBatch(ctx)
+		if err != nil {
+			return err
+		}
+
+		if len(published) > 0 {
+			logging.FromContext(ctx).Info("published overdue scheduled chirps", "count", len(published))
+		}
+
+		// a full batch means there may be more waiting
+		if len(published) < scheduledChirpBatchSize {
+			return nil
+		}
+	}
+}
+
+// publishChirpBatch publishes one batch and queues its chirp.created
+// webhooks in the same transaction.
+func (cfg *apiConfig) publishChirpBatch(ctx context.Context) ([]database.Chirp, error) {
+
+	tx, err := cfg.dbConn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.withTx(tx)
+
+	published, err := qtx.PublishDueChirps(ctx, scheduledChirpBatchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, chirp := range published {
+		err = enqueueEvent(ctx, qtx, webhook.ChirpCreated, newChirpEvent(chirp), chirp.UserID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return published, tx.Commit()
+}
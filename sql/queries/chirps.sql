@@ -1,27 +1,147 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, visibility, status, published_at)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    'published',
+    NOW()
+)
+RETURNING *;
+
+-- name: CreateUnpublishedChirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, visibility, status, publish_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
 )
 RETURNING *;
 
 -- name: GetAllChirps :many
+-- Only returns chirps the viewer may see: public ones, their own, and
+-- followers-only ones of users they follow. Unlisted chirps are left out.
+-- Chirps hidden by moderation, and chirps of banned, suspended or
+-- shadow-limited users, are only shown to their author.
+-- Anonymous viewers pass the nil uuid.
 SELECT * FROM chirps
-ORDER BY created_at ASC; 
+WHERE chirps.status = 'published'
+    AND (
+        chirps.user_id = sqlc.arg(viewer_id)
+        OR (chirps.hidden_at IS NULL AND NOT EXISTS (
+            SELECT 1 FROM users
+            WHERE users.id = chirps.user_id
+                AND (users.banned_at IS NOT NULL OR users.shadow_limited OR users.suspended_until > NOW())
+        ))
+    )
+    AND (
+        chirps.visibility = 'public'
+        OR chirps.user_id = sqlc.arg(viewer_id)
+        OR (chirps.visibility = 'followers' AND EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = sqlc.arg(viewer_id) AND follows.followee_id = chirps.user_id
+        ))
+    )
+ORDER BY published_at ASC; 
 
 -- name: GetAChirp :one
+-- Like GetAllChirps, but unlisted chirps can be opened directly.
+SELECT * FROM chirps
+where chirps.id = sqlc.arg(id) AND chirps.status = 'published'
+    AND (
+        chirps.user_id = sqlc.arg(viewer_id)
+        OR (chirps.hidden_at IS NULL AND NOT EXISTS (
+            SELECT 1 FROM users
+            WHERE users.id = chirps.user_id
+                AND (users.banned_at IS NOT NULL OR users.shadow_limited OR users.suspended_until > NOW())
+        ))
+    )
+    AND (
+        chirps.visibility IN ('public', 'unlisted')
+        OR chirps.user_id = sqlc.arg(viewer_id)
+        OR (chirps.visibility = 'followers' AND EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = sqlc.arg(viewer_id) AND follows.followee_id = chirps.user_id
+        ))
+    );
+
+-- name: GetChirpByID :one
+-- Ignores visibility; only for moderation and other internal lookups.
 SELECT * FROM chirps
-where id = $1;
+WHERE id = $1;
 
 -- name: DeleteAChirp :exec
 DELETE FROM chirps
 WHERE id = $1; 
 
 -- name: GetChirpsFromAuthor :many
+-- Same visibility rules as GetAllChirps: unlisted chirps are left out
+-- unless the viewer wrote them.
+SELECT * FROM chirps
+where chirps.user_id = sqlc.arg(user_id) AND chirps.status = 'published'
+    AND (
+        chirps.user_id = sqlc.arg(viewer_id)
+        OR (chirps.hidden_at IS NULL AND NOT EXISTS (
+            SELECT 1 FROM users
+            WHERE users.id = chirps.user_id
+                AND (users.banned_at IS NOT NULL OR users.shadow_limited OR users.suspended_until > NOW())
+        ))
+    )
+    AND (
+        chirps.visibility = 'public'
+        OR chirps.user_id = sqlc.arg(viewer_id)
+        OR (chirps.visibility = 'followers' AND EXISTS (
+            SELECT 1 FROM follows
+            WHERE follows.follower_id = sqlc.arg(viewer_id) AND follows.followee_id = chirps.user_id
+        ))
+    )
+ORDER BY published_at ASC;
+
+-- name: GetUnpublishedChirpsForUser :many
 SELECT * FROM chirps
-where user_id = $1
-ORDER BY created_at ASC;
\ No newline at end of file
+WHERE user_id = $1 AND status <> 'published'
+ORDER BY publish_at ASC NULLS LAST, created_at ASC;
+
+-- name: UpdateUnpublishedChirp :one
+UPDATE chirps
+SET updated_at = NOW(), body = $1, visibility = $2, status = $3, publish_at = $4
+WHERE id = $5 AND user_id = $6 AND status <> 'published'
+RETURNING *;
+
+-- name: DeleteUnpublishedChirp :execrows
+DELETE FROM chirps
+WHERE id = $1 AND user_id = $2 AND status <> 'published';
+
+-- name: EditChirp :one
+UPDATE chirps
+SET updated_at = NOW(), edited_at = NOW(), body = $1
+WHERE id = $2 AND user_id = $3 AND status = 'published'
+RETURNING *;
+
+-- name: PublishDueChirps :many
+UPDATE chirps
+SET updated_at = NOW(), status = 'published', published_at = NOW()
+WHERE id IN (
+    SELECT id FROM chirps
+    WHERE status = 'scheduled' AND publish_at <= NOW()
+    ORDER BY publish_at ASC
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: PublishScheduledChirp :one
+-- Publishes the chirp only if it is still scheduled and due, so a job for a
+-- chirp that was since rescheduled, made a draft or deleted does nothing.
+UPDATE chirps
+SET updated_at = NOW(), status = 'published', published_at = NOW()
+WHERE id = $1 AND status = 'scheduled' AND publish_at <= NOW()
+RETURNING *;
@@ -0,0 +1,38 @@
+-- name: ListFilterRules :many
+SELECT * FROM filter_rules
+ORDER BY created_at ASC;
+
+-- name: ListEnabledFilterRules :many
+SELECT * FROM filter_rules
+WHERE enabled = true;
+
+-- name: CreateFilterRule :one
+INSERT INTO filter_rules (id, created_at, updated_at, pattern, action, enabled)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: UpdateFilterRule :one
+UPDATE filter_rules
+SET updated_at = NOW(), pattern = $1, action = $2, enabled = $3
+WHERE id = $4
+RETURNING *;
+
+-- name: DeleteFilterRule :execrows
+DELETE FROM filter_rules
+WHERE id = $1;
+
+-- name: CreateChirpFlag :exec
+INSERT INTO chirp_flags (chirp_id, rule_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT DO NOTHING;
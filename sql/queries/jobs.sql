@@ -0,0 +1,46 @@
+-- name: InsertJob :execrows
+INSERT INTO jobs (id, created_at, updated_at, queue, kind, payload, max_attempts, run_at, unique_key)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+ON CONFLICT (unique_key) DO NOTHING;
+
+-- name: ClaimJobs :many
+-- Leases up to $2 due jobs from queue $1 for $3 seconds. Jobs left running
+-- by a worker that died become due again once their lease is up.
+UPDATE jobs
+SET updated_at = NOW(), status = 'running', attempts = attempts + 1,
+    locked_until = NOW() + $3::integer * INTERVAL '1 second'
+WHERE id IN (
+    SELECT id FROM jobs
+    WHERE queue = $1
+        AND ((status = 'available' AND run_at <= NOW())
+            OR (status = 'running' AND locked_until <= NOW()))
+    ORDER BY run_at ASC
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: CompleteJob :exec
+UPDATE jobs
+SET updated_at = NOW(), status = 'completed', locked_until = NULL, finished_at = NOW()
+WHERE id = $1;
+
+-- name: RetryJob :exec
+UPDATE jobs
+SET updated_at = NOW(), status = 'available', locked_until = NULL, run_at = $2, last_error = $3
+WHERE id = $1;
+
+-- name: FailJob :exec
+UPDATE jobs
+SET updated_at = NOW(), status = 'failed', locked_until = NULL, last_error = $2, finished_at = NOW()
+WHERE id = $1;
@@ -0,0 +1,46 @@
+-- name: CreateMedia :one
+INSERT INTO media (id, created_at, user_id, content_type, width, height, size_bytes, storage_key, thumbnail_key)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8
+)
+RETURNING *;
+
+-- name: GetMedia :one
+SELECT * FROM media
+WHERE id = $1;
+
+-- name: AttachMediaToChirp :execrows
+UPDATE media
+SET chirp_id = $1, position = $2
+WHERE id = $3 AND user_id = $4 AND chirp_id IS NULL;
+
+-- name: GetMediaForChirps :many
+SELECT * FROM media
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::UUID[])
+ORDER BY chirp_id, position ASC;
+
+-- name: CountPendingMedia :one
+-- Uploads the user hasn't attached to a chirp yet.
+SELECT COUNT(*) FROM media
+WHERE user_id = $1 AND chirp_id IS NULL;
+
+-- name: PruneUnattachedMedia :many
+-- Deletes up to $2 uploads created before $1 that were never attached to a
+-- chirp. chirp_id is checked again outside the subquery so an upload
+-- attached while this waits for its row lock is left alone.
+DELETE FROM media
+WHERE chirp_id IS NULL AND created_at < $1 AND id IN (
+    SELECT id FROM media
+    WHERE chirp_id IS NULL AND created_at < $1
+    ORDER BY created_at ASC
+    LIMIT $2
+)
+RETURNING *;
@@ -0,0 +1,143 @@
+-- name: OpenModerationCase :one
+INSERT INTO moderation_cases (id, created_at, updated_at, chirp_id, status)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    'open'
+)
+ON CONFLICT (chirp_id) WHERE status <> 'resolved'
+DO UPDATE SET updated_at = NOW()
+RETURNING *;
+
+-- name: CreateReport :execrows
+INSERT INTO reports (id, created_at, case_id, chirp_id, reporter_id, reason, details)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+ON CONFLICT (case_id, reporter_id) DO NOTHING;
+
+-- name: CountCaseReporters :one
+-- Reports from shadow-limited users don't count towards automatic hiding.
+SELECT COUNT(DISTINCT reports.reporter_id) FROM reports
+JOIN users ON users.id = reports.reporter_id
+WHERE reports.case_id = $1 AND NOT users.shadow_limited;
+
+-- name: GetCaseReports :many
+SELECT * FROM reports
+WHERE case_id = $1
+ORDER BY created_at ASC;
+
+-- name: HideChirp :execrows
+UPDATE chirps
+SET updated_at = NOW(), hidden_at = NOW()
+WHERE id = $1 AND hidden_at IS NULL;
+
+-- name: UnhideChirp :exec
+UPDATE chirps
+SET updated_at = NOW(), hidden_at = NULL
+WHERE id = $1;
+
+-- name: ListModerationCases :many
+-- The chirp columns are NULL when the chirp has since been deleted.
+SELECT moderation_cases.*,
+    chirps.body AS chirp_body,
+    chirps.user_id AS author_id,
+    chirps.hidden_at AS chirp_hidden_at,
+    (SELECT COUNT(*) FROM reports WHERE reports.case_id = moderation_cases.id) AS report_count
+FROM moderation_cases
+LEFT JOIN chirps ON chirps.id = moderation_cases.chirp_id
+WHERE moderation_cases.status = $1
+ORDER BY moderation_cases.created_at ASC
+LIMIT 100;
+
+-- name: GetModerationCase :one
+SELECT * FROM moderation_cases
+WHERE id = $1;
+
+-- name: ClaimModerationCase :one
+UPDATE moderation_cases
+SET updated_at = NOW(), status = 'claimed', claimed_by = $1, claimed_at = NOW()
+WHERE id = $2 AND status = 'open'
+RETURNING *;
+
+-- name: ResolveModerationCase :one
+UPDATE moderation_cases
+SET updated_at = NOW(), status = 'resolved', resolution = $1, resolved_by = $2, resolved_at = NOW()
+WHERE id = $3 AND status = 'claimed' AND claimed_by = $2
+RETURNING *;
+
+-- name: CreateModerationAction :exec
+INSERT INTO moderation_actions (id, created_at, case_id, subject_user_id, moderator_id, action, note)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+);
+
+-- name: ListModerationActions :many
+SELECT * FROM moderation_actions
+WHERE case_id = $1
+ORDER BY created_at ASC;
+
+-- name: ListAccountActions :many
+SELECT * FROM moderation_actions
+WHERE subject_user_id = $1
+ORDER BY created_at ASC;
+
+-- name: SuspendUser :exec
+UPDATE users
+SET updated_at = NOW(), suspended_until = $1, suspension_reason = $2
+WHERE id = $3;
+
+-- name: SetUserStanding :one
+UPDATE users
+SET updated_at = NOW(), suspended_until = $1, banned_at = $2, shadow_limited = $3, suspension_reason = $4
+WHERE id = $5
+RETURNING *;
+
+-- name: LiftUserRestrictions :exec
+UPDATE users
+SET updated_at = NOW(), suspended_until = NULL, banned_at = NULL, suspension_reason = ''
+WHERE id = $1;
+
+-- name: CreateAppeal :one
+INSERT INTO appeals (id, created_at, updated_at, user_id, message, status)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    'open'
+)
+RETURNING *;
+
+-- name: ListAppeals :many
+SELECT appeals.*,
+    users.handle,
+    users.suspended_until,
+    users.banned_at,
+    users.suspension_reason
+FROM appeals
+JOIN users ON users.id = appeals.user_id
+WHERE appeals.status = $1
+ORDER BY appeals.created_at ASC
+LIMIT 100;
+
+-- name: ReviewAppeal :one
+UPDATE appeals
+SET updated_at = NOW(), status = $1, response = $2, reviewed_by = $3, reviewed_at = NOW()
+WHERE id = $4 AND status = 'open'
+RETURNING *;
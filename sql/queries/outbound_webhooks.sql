@@ -0,0 +1,92 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, created_at, updated_at, owner_id, url, event_types, secret, all_users)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+)
+RETURNING *;
+
+-- name: ListWebhookEndpoints :many
+SELECT * FROM webhook_endpoints
+WHERE owner_id = $1
+ORDER BY created_at ASC;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints
+WHERE id = $1 AND owner_id = $2;
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints
+WHERE id = $1 AND owner_id = $2;
+
+-- name: EnqueueWebhookDeliveries :execrows
+-- Queues the event for every endpoint that wants it: endpoints listening to
+-- all users, and endpoints owned by one of the users the event is about.
+INSERT INTO webhook_deliveries (id, created_at, updated_at, endpoint_id, event_id, event_type, payload, status, attempts, next_attempt_at)
+SELECT gen_random_uuid(), NOW(), NOW(), id, $1, $2, $3, 'pending', 0, NOW()
+FROM webhook_endpoints
+WHERE $2 = ANY(event_types)
+    AND (all_users OR owner_id = ANY($4::uuid[]));
+
+-- name: ClaimDueWebhookDeliveries :many
+-- Takes up to $1 due deliveries and leases them for five minutes by pushing
+-- next_attempt_at forward, so no transaction is held open while sending.
+-- If this process dies mid-send the lease runs out and another one retries.
+WITH claimed AS (
+    UPDATE webhook_deliveries
+    SET updated_at = NOW(), attempts = attempts + 1, next_attempt_at = NOW() + INTERVAL '5 minutes'
+    WHERE webhook_deliveries.id IN (
+        SELECT id FROM webhook_deliveries
+        WHERE status = 'pending' AND next_attempt_at <= NOW()
+        ORDER BY next_attempt_at ASC
+        LIMIT $1
+        FOR UPDATE SKIP LOCKED
+    )
+    RETURNING webhook_deliveries.id, webhook_deliveries.endpoint_id, webhook_deliveries.event_type, webhook_deliveries.payload, webhook_deliveries.attempts
+)
+SELECT claimed.id, claimed.endpoint_id, claimed.event_type, claimed.payload, claimed.attempts, webhook_endpoints.url, webhook_endpoints.secret,
+    users.role = 'admin' AS owner_is_admin
+FROM claimed
+JOIN webhook_endpoints ON webhook_endpoints.id = claimed.endpoint_id
+JOIN users ON users.id = webhook_endpoints.owner_id;
+
+-- name: FinishWebhookAttempt :exec
+UPDATE webhook_deliveries
+SET updated_at = NOW(), last_attempt_at = NOW(), status = $1, next_attempt_at = $2, delivered_at = $3
+WHERE id = $4;
+
+-- name: CreateWebhookAttempt :exec
+INSERT INTO webhook_delivery_attempts (id, delivery_id, attempted_at, status_code, error, duration_ms)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5
+);
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
+
+-- name: ListWebhookAttempts :many
+SELECT * FROM webhook_delivery_attempts
+WHERE delivery_id = ANY($1::uuid[])
+ORDER BY attempted_at ASC;
+
+-- name: RedeliverWebhook :one
+-- Puts a delivery back in the queue with a fresh set of attempts. Its
+-- earlier attempts stay in the log.
+UPDATE webhook_deliveries
+SET updated_at = NOW(), status = 'pending', attempts = 0, next_attempt_at = NOW()
+WHERE id = $1 AND endpoint_id = $2
+RETURNING *;
@@ -0,0 +1,19 @@
+-- name: GetUserPlan :one
+SELECT * FROM user_plans
+WHERE user_id = $1;
+
+-- name: ListPlans :many
+SELECT * FROM plans
+ORDER BY id ASC;
+
+-- name: UpdatePlan :one
+UPDATE plans
+SET updated_at = NOW(), name = $1, max_chirp_length = $2, edit_window_seconds = $3, chirps_per_hour = $4,
+    max_media_per_chirp = $5, can_schedule = $6, badge = $7
+WHERE id = $8
+RETURNING *;
+
+-- name: CountChirpsSince :one
+-- Drafts don't count towards the quota until they are posted.
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1 AND created_at > $2 AND status <> 'draft';
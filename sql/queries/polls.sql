@@ -0,0 +1,60 @@
+-- name: CreatePoll :one
+INSERT INTO polls (id, created_at, chirp_id, closes_at, multiple_choice, hide_results)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: CreatePollOption :one
+INSERT INTO poll_options (id, poll_id, position, text)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: GetPollByChirpID :one
+SELECT * FROM polls
+WHERE chirp_id = $1;
+
+-- name: GetPollsForChirps :many
+SELECT polls.*,
+    (SELECT COUNT(*) FROM poll_ballots WHERE poll_ballots.poll_id = polls.id) AS voter_count
+FROM polls
+WHERE polls.chirp_id = ANY(sqlc.arg(chirp_ids)::UUID[]);
+
+-- name: GetPollTallies :many
+SELECT poll_options.*, COUNT(poll_votes.option_id) AS votes
+FROM poll_options
+LEFT JOIN poll_votes ON poll_votes.option_id = poll_options.id
+WHERE poll_options.poll_id = ANY(sqlc.arg(poll_ids)::UUID[])
+GROUP BY poll_options.id
+ORDER BY poll_options.poll_id, poll_options.position ASC;
+
+-- name: GetViewerPollVotes :many
+SELECT poll_id, option_id FROM poll_votes
+WHERE user_id = sqlc.arg(user_id) AND poll_id = ANY(sqlc.arg(poll_ids)::UUID[]);
+
+-- name: CreatePollBallot :execrows
+INSERT INTO poll_ballots (poll_id, user_id, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: CreatePollVote :exec
+INSERT INTO poll_votes (poll_id, user_id, option_id)
+VALUES (
+    $1,
+    $2,
+    $3
+);
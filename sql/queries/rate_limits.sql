@@ -0,0 +1,15 @@
+-- name: TakeRateLimit :one
+-- GCRA: moves key $1's tat on by $2 microseconds if that leaves it at most
+-- $3 microseconds ahead, using the database clock so replicas agree.
+-- Returns no row when the request is refused.
+INSERT INTO rate_limits AS r (key, tat)
+VALUES ($1, NOW() + $2::bigint * INTERVAL '1 microsecond')
+ON CONFLICT (key) DO UPDATE
+SET tat = GREATEST(r.tat, NOW()) + $2::bigint * INTERVAL '1 microsecond'
+WHERE GREATEST(r.tat, NOW()) + $2::bigint * INTERVAL '1 microsecond'
+    <= NOW() + $3::bigint * INTERVAL '1 microsecond'
+RETURNING tat, NOW()::timestamp AS now;
+
+-- name: GetRateLimit :one
+SELECT tat, NOW()::timestamp AS now FROM rate_limits
+WHERE key = $1;
@@ -0,0 +1,43 @@
+-- name: TryAdvisoryLock :one
+SELECT pg_try_advisory_lock($1);
+
+-- name: AdvisoryUnlock :exec
+SELECT pg_advisory_unlock($1);
+
+-- name: PruneRefreshTokens :execrows
+-- Deletes up to $2 tokens that expired or were revoked before $1.
+DELETE FROM refresh_tokens
+WHERE token IN (
+    SELECT token FROM refresh_tokens
+    WHERE expires_at < $1 OR revoked_at < $1
+    LIMIT $2
+);
+
+-- name: PruneJobs :execrows
+-- Deletes up to $2 completed or failed jobs that finished before $1.
+DELETE FROM jobs
+WHERE id IN (
+    SELECT id FROM jobs
+    WHERE status IN ('completed', 'failed') AND finished_at < $1
+    LIMIT $2
+);
+
+-- name: PruneWebhookDeliveries :execrows
+-- Deletes up to $2 delivered or dead deliveries created before $1, with
+-- their attempt logs. Pending ones are kept however old they are.
+DELETE FROM webhook_deliveries
+WHERE id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status IN ('delivered', 'dead') AND created_at < $1
+    LIMIT $2
+);
+
+-- name: PruneRateLimits :execrows
+-- Deletes up to $1 buckets that are full again, by the database clock
+-- TakeRateLimit uses.
+DELETE FROM rate_limits
+WHERE key IN (
+    SELECT key FROM rate_limits
+    WHERE tat < NOW()::timestamp
+    LIMIT $1
+);
@@ -0,0 +1,45 @@
+-- name: GetSubscription :one
+SELECT * FROM subscriptions
+WHERE user_id = $1;
+
+-- name: UpsertSubscription :one
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan_id, status, current_period_start, current_period_end)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    'active',
+    $3,
+    $4
+)
+ON CONFLICT (user_id) DO UPDATE
+SET updated_at = NOW(),
+    plan_id = EXCLUDED.plan_id,
+    status = 'active',
+    current_period_start = EXCLUDED.current_period_start,
+    current_period_end = EXCLUDED.current_period_end,
+    canceled_at = NULL
+RETURNING *;
+
+-- name: SetSubscriptionStatus :one
+UPDATE subscriptions
+SET updated_at = NOW(), status = $1, current_period_end = $2, canceled_at = $3
+WHERE user_id = $4
+RETURNING *;
+
+-- name: CreateWebhookEvent :execrows
+-- Returns 0 rows when the provider already delivered this event.
+INSERT INTO webhook_events (id, received_at, provider, event_id, event_type, user_id, payload, outcome)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6
+)
+ON CONFLICT (provider, event_id) DO NOTHING;
@@ -1,11 +1,12 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
 )
 RETURNING *;
 
@@ -16,13 +17,33 @@ DELETE FROM users;
 SELECT * FROM users
 WHERE email = $1;
 
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = $1;
+
+-- name: GetUserByHandle :one
+SELECT * FROM users
+WHERE LOWER(handle) = LOWER(sqlc.arg(handle));
+
+-- name: GetUserProfileByHandle :one
+SELECT users.*,
+    (SELECT COUNT(*) FROM follows WHERE follows.followee_id = users.id) AS follower_count,
+    (SELECT COUNT(*) FROM follows WHERE follows.follower_id = users.id) AS following_count,
+    (SELECT COUNT(*) FROM chirps WHERE chirps.user_id = users.id AND chirps.status = 'published' AND chirps.visibility = 'public' AND chirps.hidden_at IS NULL) AS chirp_count,
+    user_plans.id AS plan_id,
+    user_plans.badge
+FROM users
+JOIN user_plans ON user_plans.user_id = users.id
+WHERE LOWER(users.handle) = LOWER(sqlc.arg(handle));
+
 -- name: UpdateUser :one
 UPDATE users
 SET updated_at = NOW(), email = $1, hashed_password = $2
 WHERE id = $3
 RETURNING *;
 
--- name: UpgradeUserChirpyRed :exec
+-- name: UpdateUserProfile :one
 UPDATE users
-SET is_chirpy_red = true
-WHERE id = $1;
\ No newline at end of file
+SET updated_at = NOW(), handle = $1, display_name = $2, bio = $3, avatar_url = $4
+WHERE id = $5
+RETURNING *;
@@ -0,0 +1,24 @@
+-- +goose Up
+ALTER TABLE users
+ADD handle TEXT,
+ADD display_name TEXT NOT NULL DEFAULT '',
+ADD bio TEXT NOT NULL DEFAULT '',
+ADD avatar_url TEXT NOT NULL DEFAULT '';
+
+UPDATE users
+SET handle = 'user_' || SUBSTRING(REPLACE(id::TEXT, '-', '') FROM 1 FOR 12)
+WHERE handle IS NULL;
+
+ALTER TABLE users
+ALTER COLUMN handle SET NOT NULL;
+
+CREATE UNIQUE INDEX users_handle_lower_idx ON users (LOWER(handle));
+
+-- +goose Down
+DROP INDEX users_handle_lower_idx;
+
+ALTER TABLE users
+DROP COLUMN handle,
+DROP COLUMN display_name,
+DROP COLUMN bio,
+DROP COLUMN avatar_url;
@@ -0,0 +1,20 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD status TEXT NOT NULL DEFAULT 'published'
+    CHECK (status IN ('draft', 'scheduled', 'published')),
+ADD publish_at TIMESTAMP,
+ADD published_at TIMESTAMP;
+
+UPDATE chirps
+SET published_at = created_at;
+
+CREATE INDEX chirps_due_idx ON chirps (publish_at)
+WHERE status = 'scheduled';
+
+-- +goose Down
+DROP INDEX chirps_due_idx;
+
+ALTER TABLE chirps
+DROP COLUMN status,
+DROP COLUMN publish_at,
+DROP COLUMN published_at;
@@ -0,0 +1,58 @@
+-- +goose Up
+CREATE TABLE polls(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    chirp_id UUID NOT NULL UNIQUE,
+    FOREIGN KEY (chirp_id)
+    REFERENCES chirps(id)
+    ON DELETE CASCADE,
+    closes_at TIMESTAMP NOT NULL,
+    multiple_choice BOOLEAN NOT NULL,
+    hide_results BOOLEAN NOT NULL
+);
+
+CREATE TABLE poll_options(
+    id UUID PRIMARY KEY,
+    poll_id UUID NOT NULL,
+    FOREIGN KEY (poll_id)
+    REFERENCES polls(id)
+    ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    text TEXT NOT NULL,
+    UNIQUE (poll_id, position)
+);
+
+-- one ballot per user and poll; a ballot holds one or more votes
+CREATE TABLE poll_ballots(
+    poll_id UUID NOT NULL,
+    FOREIGN KEY (poll_id)
+    REFERENCES polls(id)
+    ON DELETE CASCADE,
+    user_id UUID NOT NULL,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (poll_id, user_id)
+);
+
+CREATE TABLE poll_votes(
+    poll_id UUID NOT NULL,
+    user_id UUID NOT NULL,
+    option_id UUID NOT NULL,
+    FOREIGN KEY (poll_id, user_id)
+    REFERENCES poll_ballots(poll_id, user_id)
+    ON DELETE CASCADE,
+    FOREIGN KEY (option_id)
+    REFERENCES poll_options(id)
+    ON DELETE CASCADE,
+    PRIMARY KEY (poll_id, user_id, option_id)
+);
+
+CREATE INDEX poll_votes_option_idx ON poll_votes (option_id);
+
+-- +goose Down
+DROP TABLE poll_votes;
+DROP TABLE poll_ballots;
+DROP TABLE poll_options;
+DROP TABLE polls;
@@ -0,0 +1,42 @@
+-- +goose Up
+ALTER TABLE users
+ADD role TEXT NOT NULL DEFAULT 'user'
+    CHECK (role IN ('user', 'moderator', 'admin'));
+
+CREATE TABLE filter_rules(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    pattern TEXT NOT NULL,
+    action TEXT NOT NULL
+        CHECK (action IN ('mask', 'reject', 'flag')),
+    enabled BOOLEAN NOT NULL DEFAULT true
+);
+
+CREATE UNIQUE INDEX filter_rules_pattern_idx ON filter_rules (LOWER(pattern));
+
+INSERT INTO filter_rules (id, created_at, updated_at, pattern, action)
+VALUES
+    (gen_random_uuid(), NOW(), NOW(), 'kerfuffle', 'mask'),
+    (gen_random_uuid(), NOW(), NOW(), 'sharbert', 'mask'),
+    (gen_random_uuid(), NOW(), NOW(), 'fornax', 'mask');
+
+CREATE TABLE chirp_flags(
+    chirp_id UUID NOT NULL,
+    FOREIGN KEY (chirp_id)
+    REFERENCES chirps(id)
+    ON DELETE CASCADE,
+    rule_id UUID NOT NULL,
+    FOREIGN KEY (rule_id)
+    REFERENCES filter_rules(id)
+    ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (chirp_id, rule_id)
+);
+
+-- +goose Down
+DROP TABLE chirp_flags;
+DROP TABLE filter_rules;
+
+ALTER TABLE users
+DROP COLUMN role;
@@ -0,0 +1,88 @@
+-- +goose Up
+ALTER TABLE chirps
+ADD hidden_at TIMESTAMP;
+
+ALTER TABLE users
+ADD suspended_until TIMESTAMP,
+ADD suspension_reason TEXT NOT NULL DEFAULT '';
+
+-- a case collects everything that needs a moderator's look at one chirp
+CREATE TABLE moderation_cases(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    chirp_id UUID NOT NULL,
+    FOREIGN KEY (chirp_id)
+    REFERENCES chirps(id)
+    ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'open'
+        CHECK (status IN ('open', 'claimed', 'resolved')),
+    claimed_by UUID,
+    FOREIGN KEY (claimed_by)
+    REFERENCES users(id)
+    ON DELETE SET NULL,
+    claimed_at TIMESTAMP,
+    resolution TEXT
+        CHECK (resolution IN ('dismiss', 'hide_chirp', 'suspend_user')),
+    resolved_by UUID,
+    FOREIGN KEY (resolved_by)
+    REFERENCES users(id)
+    ON DELETE SET NULL,
+    resolved_at TIMESTAMP
+);
+
+-- at most one unresolved case per chirp
+CREATE UNIQUE INDEX moderation_cases_active_idx ON moderation_cases (chirp_id)
+WHERE status <> 'resolved';
+
+CREATE TABLE reports(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    case_id UUID NOT NULL,
+    FOREIGN KEY (case_id)
+    REFERENCES moderation_cases(id)
+    ON DELETE CASCADE,
+    chirp_id UUID NOT NULL,
+    FOREIGN KEY (chirp_id)
+    REFERENCES chirps(id)
+    ON DELETE CASCADE,
+    reporter_id UUID NOT NULL,
+    FOREIGN KEY (reporter_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+    reason TEXT NOT NULL
+        CHECK (reason IN ('spam', 'harassment', 'hate', 'violence', 'misinformation', 'other')),
+    details TEXT NOT NULL DEFAULT '',
+    UNIQUE (chirp_id, reporter_id)
+);
+
+-- append only: rows are never updated or deleted by the application
+CREATE TABLE moderation_actions(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    case_id UUID NOT NULL,
+    FOREIGN KEY (case_id)
+    REFERENCES moderation_cases(id)
+    ON DELETE CASCADE,
+    -- NULL for actions taken automatically
+    moderator_id UUID,
+    FOREIGN KEY (moderator_id)
+    REFERENCES users(id)
+    ON DELETE SET NULL,
+    action TEXT NOT NULL,
+    note TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX moderation_actions_case_idx ON moderation_actions (case_id);
+
+-- +goose Down
+DROP TABLE moderation_actions;
+DROP TABLE reports;
+DROP TABLE moderation_cases;
+
+ALTER TABLE users
+DROP COLUMN suspended_until,
+DROP COLUMN suspension_reason;
+
+ALTER TABLE chirps
+DROP COLUMN hidden_at;
@@ -0,0 +1,48 @@
+-- +goose Up
+-- suspended_until (from 013) covers temporary suspensions; a ban has no end
+ALTER TABLE users
+ADD banned_at TIMESTAMP,
+ADD shadow_limited BOOLEAN NOT NULL DEFAULT false;
+
+-- moderation actions can now target an account directly instead of a case
+ALTER TABLE moderation_actions
+ALTER COLUMN case_id DROP NOT NULL,
+ADD subject_user_id UUID REFERENCES users(id) ON DELETE CASCADE;
+
+CREATE INDEX moderation_actions_subject_idx ON moderation_actions (subject_user_id);
+
+CREATE TABLE appeals(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+    message TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'open'
+        CHECK (status IN ('open', 'accepted', 'rejected')),
+    response TEXT NOT NULL DEFAULT '',
+    reviewed_by UUID,
+    FOREIGN KEY (reviewed_by)
+    REFERENCES users(id)
+    ON DELETE SET NULL,
+    reviewed_at TIMESTAMP
+);
+
+-- one pending appeal per user
+CREATE UNIQUE INDEX appeals_open_idx ON appeals (user_id)
+WHERE status = 'open';
+
+-- +goose Down
+DROP TABLE appeals;
+
+DELETE FROM moderation_actions WHERE case_id IS NULL;
+
+ALTER TABLE moderation_actions
+DROP COLUMN subject_user_id,
+ALTER COLUMN case_id SET NOT NULL;
+
+ALTER TABLE users
+DROP COLUMN banned_at,
+DROP COLUMN shadow_limited;
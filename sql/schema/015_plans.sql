@@ -0,0 +1,37 @@
+-- +goose Up
+-- Limits for each plan live here so they can be tuned without a deploy.
+CREATE TABLE plans(
+    id TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    name TEXT NOT NULL,
+    max_chirp_length INTEGER NOT NULL CHECK (max_chirp_length > 0),
+    -- 0 means published chirps can't be edited
+    edit_window_seconds INTEGER NOT NULL CHECK (edit_window_seconds >= 0),
+    chirps_per_hour INTEGER NOT NULL CHECK (chirps_per_hour > 0),
+    max_media_per_chirp INTEGER NOT NULL CHECK (max_media_per_chirp >= 0),
+    can_schedule BOOLEAN NOT NULL,
+    -- shown on public profiles; empty for no badge
+    badge TEXT NOT NULL DEFAULT ''
+);
+
+INSERT INTO plans (id, created_at, updated_at, name, max_chirp_length, edit_window_seconds, chirps_per_hour, max_media_per_chirp, can_schedule, badge)
+VALUES
+    ('free', NOW(), NOW(), 'Free', 140, 0, 30, 4, false, ''),
+    ('red', NOW(), NOW(), 'Chirpy Red', 280, 300, 300, 8, true, 'chirpy_red');
+
+-- the plan each user is on; the only place that knows how users map to plans
+CREATE VIEW user_plans AS
+SELECT users.id AS user_id, plans.*
+FROM users
+JOIN plans ON plans.id = CASE WHEN users.is_chirpy_red THEN 'red' ELSE 'free' END;
+
+ALTER TABLE chirps
+ADD edited_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE chirps
+DROP COLUMN edited_at;
+
+DROP VIEW user_plans;
+DROP TABLE plans;
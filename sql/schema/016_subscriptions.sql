@@ -0,0 +1,66 @@
+-- +goose Up
+CREATE TABLE subscriptions(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL UNIQUE,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+    plan_id TEXT NOT NULL REFERENCES plans(id),
+    -- past_due and canceled keep access until current_period_end; refunded
+    -- ends it right away
+    status TEXT NOT NULL
+        CHECK (status IN ('active', 'past_due', 'canceled', 'refunded')),
+    current_period_start TIMESTAMP NOT NULL,
+    current_period_end TIMESTAMP NOT NULL,
+    canceled_at TIMESTAMP
+);
+
+-- every webhook we accept, as received; rows are never updated or deleted
+CREATE TABLE webhook_events(
+    id UUID PRIMARY KEY,
+    received_at TIMESTAMP NOT NULL,
+    provider TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    user_id UUID,
+    FOREIGN KEY (user_id)
+    REFERENCES users(id)
+    ON DELETE SET NULL,
+    payload JSONB NOT NULL,
+    outcome TEXT NOT NULL
+);
+
+CREATE INDEX webhook_events_user_idx ON webhook_events (user_id);
+
+-- existing Red users get a period to run out like everyone else's
+INSERT INTO subscriptions (id, created_at, updated_at, user_id, plan_id, status, current_period_start, current_period_end)
+SELECT gen_random_uuid(), NOW(), NOW(), id, 'red', 'active', NOW(), NOW() + INTERVAL '30 days'
+FROM users
+WHERE is_chirpy_red;
+
+CREATE OR REPLACE VIEW user_plans AS
+SELECT users.id AS user_id, plans.*
+FROM users
+LEFT JOIN subscriptions ON subscriptions.user_id = users.id
+    AND subscriptions.status <> 'refunded'
+    AND subscriptions.current_period_end > NOW()
+JOIN plans ON plans.id = COALESCE(subscriptions.plan_id, 'free');
+
+ALTER TABLE users
+DROP COLUMN is_chirpy_red;
+
+-- +goose Down
+ALTER TABLE users
+ADD is_chirpy_red BOOLEAN NOT NULL DEFAULT false;
+
+UPDATE users SET is_chirpy_red = true
+WHERE id IN (SELECT user_id FROM user_plans WHERE id <> 'free');
+
+CREATE OR REPLACE VIEW user_plans AS
+SELECT users.id AS user_id, plans.*
+FROM users
+JOIN plans ON plans.id = CASE WHEN users.is_chirpy_red THEN 'red' ELSE 'free' END;
+
+DROP TABLE webhook_events;
+DROP TABLE subscriptions;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- providers retry deliveries, so each event is only processed once per id;
+-- rows from before this migration have no id
+ALTER TABLE webhook_events ADD COLUMN event_id TEXT;
+
+CREATE UNIQUE INDEX webhook_events_event_idx ON webhook_events (provider, event_id);
+
+-- +goose Down
+DROP INDEX webhook_events_event_idx;
+
+ALTER TABLE webhook_events DROP COLUMN event_id;
@@ -0,0 +1,64 @@
+-- +goose Up
+-- endpoints that want to hear about Chirpy events. Users get events about
+-- themselves; admins can register endpoints that get everyone's.
+CREATE TABLE webhook_endpoints(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    owner_id UUID NOT NULL,
+    FOREIGN KEY (owner_id)
+    REFERENCES users(id)
+    ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    event_types TEXT[] NOT NULL,
+    secret TEXT NOT NULL,
+    all_users BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX webhook_endpoints_owner_idx ON webhook_endpoints (owner_id);
+
+-- the outbox: one row per event per endpoint, written in the same
+-- transaction as the change that caused it
+CREATE TABLE webhook_deliveries(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    endpoint_id UUID NOT NULL,
+    FOREIGN KEY (endpoint_id)
+    REFERENCES webhook_endpoints(id)
+    ON DELETE CASCADE,
+    event_id UUID NOT NULL,
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    -- dead deliveries ran out of attempts and wait for a manual redeliver
+    status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'delivered', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    last_attempt_at TIMESTAMP,
+    delivered_at TIMESTAMP
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at)
+    WHERE status = 'pending';
+CREATE INDEX webhook_deliveries_endpoint_idx ON webhook_deliveries (endpoint_id, created_at DESC);
+
+CREATE TABLE webhook_delivery_attempts(
+    id UUID PRIMARY KEY,
+    delivery_id UUID NOT NULL,
+    FOREIGN KEY (delivery_id)
+    REFERENCES webhook_deliveries(id)
+    ON DELETE CASCADE,
+    attempted_at TIMESTAMP NOT NULL,
+    -- NULL when the request failed before a response came back
+    status_code INTEGER,
+    error TEXT NOT NULL DEFAULT '',
+    duration_ms INTEGER NOT NULL
+);
+
+CREATE INDEX webhook_delivery_attempts_delivery_idx ON webhook_delivery_attempts (delivery_id);
+
+-- +goose Down
+DROP TABLE webhook_delivery_attempts;
+DROP TABLE webhook_deliveries;
+DROP TABLE webhook_endpoints;
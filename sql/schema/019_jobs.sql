@@ -0,0 +1,28 @@
+-- +goose Up
+-- background work, usually enqueued in the same transaction as the change
+-- that needs it
+CREATE TABLE jobs(
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    queue TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    -- running jobs whose locked_until has passed are claimed again
+    status TEXT NOT NULL DEFAULT 'available'
+        CHECK (status IN ('available', 'running', 'completed', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL,
+    run_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP,
+    last_error TEXT NOT NULL DEFAULT '',
+    -- NULL keys never conflict, so only jobs that ask for it are deduplicated
+    unique_key TEXT UNIQUE,
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX jobs_due_idx ON jobs (queue, run_at)
+    WHERE status IN ('available', 'running');
+
+-- +goose Down
+DROP TABLE jobs;
@@ -0,0 +1,15 @@
+-- +goose Up
+-- let the retention job find old rows without scanning whole tables
+CREATE INDEX refresh_tokens_expires_idx ON refresh_tokens (expires_at);
+CREATE INDEX refresh_tokens_revoked_idx ON refresh_tokens (revoked_at)
+    WHERE revoked_at IS NOT NULL;
+CREATE INDEX jobs_finished_idx ON jobs (finished_at)
+    WHERE status IN ('completed', 'failed');
+CREATE INDEX webhook_deliveries_finished_idx ON webhook_deliveries (created_at)
+    WHERE status IN ('delivered', 'dead');
+
+-- +goose Down
+DROP INDEX webhook_deliveries_finished_idx;
+DROP INDEX jobs_finished_idx;
+DROP INDEX refresh_tokens_revoked_idx;
+DROP INDEX refresh_tokens_expires_idx;
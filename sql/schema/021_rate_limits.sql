@@ -0,0 +1,15 @@
+-- +goose Up
+-- rate limit state shared by replicas, one row per client and policy;
+-- see internal/ratelimit. It's only worth keeping while the server runs,
+-- so the table is unlogged: faster writes, emptied after a crash.
+CREATE UNLOGGED TABLE rate_limits(
+    key TEXT PRIMARY KEY,
+    -- when the client's bucket will be full again
+    tat TIMESTAMP NOT NULL
+);
+
+-- rows whose tat has passed are full buckets and can go
+CREATE INDEX rate_limits_tat_idx ON rate_limits (tat);
+
+-- +goose Down
+DROP TABLE rate_limits;
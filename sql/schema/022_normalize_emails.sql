@@ -0,0 +1,9 @@
+-- +goose Up
+-- emails are now stored trimmed and in lower case, and looked up the same
+-- way. If two accounts only differ in case this fails on the UNIQUE
+-- constraint; merge or rename one of them and run it again.
+UPDATE users SET email = lower(btrim(email)) WHERE email <> lower(btrim(email));
+
+-- +goose Down
+-- the original spelling isn't kept, and lower case addresses still work
+SELECT 1;
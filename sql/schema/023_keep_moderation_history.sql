@@ -0,0 +1,21 @@
+-- +goose Up
+-- cases and reports outlive the chirp they are about, so deleting a
+-- reported chirp no longer takes the moderation history with it. The
+-- chirp id stays on the rows for the record, without a foreign key.
+ALTER TABLE moderation_cases
+DROP CONSTRAINT moderation_cases_chirp_id_fkey;
+
+ALTER TABLE reports
+DROP CONSTRAINT reports_chirp_id_fkey;
+
+-- +goose Down
+DELETE FROM reports WHERE chirp_id NOT IN (SELECT id FROM chirps);
+DELETE FROM moderation_cases WHERE chirp_id NOT IN (SELECT id FROM chirps);
+
+ALTER TABLE reports
+ADD CONSTRAINT reports_chirp_id_fkey
+FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE CASCADE;
+
+ALTER TABLE moderation_cases
+ADD CONSTRAINT moderation_cases_chirp_id_fkey
+FOREIGN KEY (chirp_id) REFERENCES chirps(id) ON DELETE CASCADE;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- uploads waiting to be attached, counted per user and pruned by age
+CREATE INDEX media_unattached_idx ON media (user_id, created_at)
+WHERE chirp_id IS NULL;
+
+-- +goose Down
+DROP INDEX media_unattached_idx;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- a user can report a chirp again once its case is resolved; the new
+-- report opens a new case. Replicas still on the previous release can't
+-- file reports until they are replaced, as they expect the old constraint.
+ALTER TABLE reports
+DROP CONSTRAINT reports_chirp_id_reporter_id_key;
+
+ALTER TABLE reports
+ADD CONSTRAINT reports_case_id_reporter_id_key UNIQUE (case_id, reporter_id);
+
+-- +goose Down
+DELETE FROM reports a USING reports b
+WHERE a.chirp_id = b.chirp_id AND a.reporter_id = b.reporter_id
+    AND (a.created_at, a.id) > (b.created_at, b.id);
+
+ALTER TABLE reports
+DROP CONSTRAINT reports_case_id_reporter_id_key;
+
+ALTER TABLE reports
+ADD CONSTRAINT reports_chirp_id_reporter_id_key UNIQUE (chirp_id, reporter_id);
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/frozendolphin/Chirpy/internal/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var httpTracer = otel.Tracer("github.com/frozendolphin/Chirpy")
+
+// traceRequests starts a server span for every request, continuing the
+// caller's trace if it sent a traceparent header. Spans from handlers and
+// queries nest under it through the request context. It sits inside
+// requestLogger, so the access log line carries the trace ID.
+func traceRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := httpTracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+				attribute.String("user_agent.original", r.UserAgent()),
+				attribute.String("client.address", r.RemoteAddr),
+			),
+		)
+		defer span.End()
+
+		rec := recorderOf(w)
+		if sc := span.SpanContext(); sc.IsValid() {
+			trace_id := sc.TraceID().String()
+			if rec != nil {
+				rec.traceID = trace_id
+			}
+			ctx = logging.WithLogger(ctx, logging.FromContext(ctx).With("trace_id", trace_id))
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+		if rec == nil {
+			return
+		}
+
+		// name the span after the route, as "GET /api/chirps/{chirpID}"
+		status := rec.statusCode()
+		if rec.route != "" {
+			span.SetName(rec.route)
+			// patterns may or may not start with a method
+			path := rec.route
+			if _, p, ok := strings.Cut(rec.route, " "); ok {
+				path = p
+			}
+			span.SetAttributes(attribute.String("http.route", path))
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}